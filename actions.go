@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/autotype"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/launcher"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/otp"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/secret"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/store"
+)
+
+// action is an operation offered in the context menu of a gopass entry.
+type action struct {
+	name string
+	run  func() error
+}
+
+// contextMenu remembers the actions of the last opened context menu, so that
+// the option IDs sent by the launcher still match them if the secret changed
+// meanwhile. The actions decrypt the value they use again, except autotype.
+var contextMenu struct {
+	sync.Mutex
+	entry   string
+	actions []action
+}
+
+// showRaw decrypts entry of s and returns its whole content.
+func showRaw(s store.Store, entry string) ([]byte, error) {
+	return s.Show(entry)
+}
+
+// showSecret decrypts entry of s and parses its content.
+func showSecret(s store.Store, entry string) (*secret.Secret, error) {
+	raw, err := showRaw(s, entry)
+	if err != nil {
+		return nil, err
+	}
+	return secret.Parse(raw), nil
+}
+
+// entryActions returns the context menu actions of entry: autotype, typing
+// the password, one per key of its secret, plus the TOTP code and opening its
+// URL when the secret has them.
+func entryActions(s store.Store, entry string) ([]action, error) {
+	raw, err := showRaw(s, entry)
+	if err != nil {
+		return nil, err
+	}
+	sec := secret.Parse(raw)
+	mode := autotype.Mode(cfg.PasteMode)
+	actions := []action{{
+		name: "Autotype",
+		run:  func() error { return spawnAutotype(entry, raw) },
+	}, {
+		name: "Type password",
+		run:  func() error { return copyAndPaste(s, entry, "", autotype.ModeType) },
+	}}
+	for _, key := range sec.Keys() {
+		actions = append(actions, action{
+			name: "Copy " + key,
+			run:  func() error { return copyAndPaste(s, entry, key, mode) },
+		})
+	}
+	if key, err := otp.FromSecret(sec); err == nil && key.Type != "hotp" {
+		actions = append(actions, action{name: "Copy OTP", run: func() error { return copyOTP(s, entry, mode) }})
+	}
+	if url, ok := sec.Get("url"); ok && url != "" {
+		actions = append(actions, action{name: "Open URL", run: func() error { return openURL(entry, url) }})
+	}
+	return actions, nil
+}
+
+func onContext(s store.Store, entry string) ([]launcher.ContextOption, error) {
+	actions, err := entryActions(s, entry)
+	if err != nil {
+		return nil, fmt.Errorf("context menu for %s: %w", entry, err)
+	}
+
+	contextMenu.Lock()
+	contextMenu.entry, contextMenu.actions = entry, actions
+	contextMenu.Unlock()
+
+	options := make([]launcher.ContextOption, len(actions))
+	for i, a := range actions {
+		options[i] = launcher.ContextOption{ID: uint32(i), Name: a.name}
+	}
+	return options, nil
+}
+
+func onActivateContext(s store.Store, entry string, optionID uint32) error {
+	contextMenu.Lock()
+	actions := contextMenu.actions
+	if contextMenu.entry != entry {
+		actions = nil
+	}
+	contextMenu.Unlock()
+
+	if actions == nil {
+		var err error
+		if actions, err = entryActions(s, entry); err != nil {
+			return err
+		}
+	}
+	if int(optionID) >= len(actions) {
+		return fmt.Errorf("unknown context option %d for %s", optionID, entry)
+	}
+	return actions[optionID].run()
+}
+
+// splitEntryKey splits an "entry:key" result name into the entry and the key.
+// The key is empty when name is a plain entry.
+func splitEntryKey(entries map[string]string, name string) (entry, key string) {
+	if _, ok := entries[strings.ToLower(name)]; ok {
+		return name, ""
+	}
+	if i := strings.LastIndexByte(name, ':'); i > 0 {
+		if original, ok := entries[strings.ToLower(name[:i])]; ok {
+			return original, name[i+1:]
+		}
+	}
+	return name, ""
+}
+
+// keyCache remembers the keys of the last entry searched with the "entry:key"
+// syntax, to avoid decrypting it on every keystroke.
+var keyCache struct {
+	sync.Mutex
+	entry string
+	keys  []string
+}
+
+// entryKeys returns the keys of the secret of entry.
+func entryKeys(s store.Store, entry string) ([]string, error) {
+	keyCache.Lock()
+	defer keyCache.Unlock()
+	if keyCache.entry == entry {
+		return keyCache.keys, nil
+	}
+	sec, err := showSecret(s, entry)
+	if err != nil {
+		return nil, err
+	}
+	keyCache.entry, keyCache.keys = entry, sec.Keys()
+	return keyCache.keys, nil
+}
+
+// activate runs the default action on the password of entry, or on the
+// value of key if not empty.
+func activate(s store.Store, entry, key string) error {
+	switch cfg.DefaultAction {
+	case "copy":
+		return copyAndPaste(s, entry, key, "")
+	case "type":
+		return copyAndPaste(s, entry, key, autotype.ModeType)
+	case "autotype":
+		if key == "" {
+			raw, err := showRaw(s, entry)
+			if err != nil {
+				return err
+			}
+			return spawnAutotype(entry, raw)
+		}
+	}
+	return copyAndPaste(s, entry, key, autotype.Mode(cfg.PasteMode))
+}
+
+// spawnAutotype spawns the paste process typing the autotype sequence of
+// the entry whose whole content is raw.
+func spawnAutotype(entry string, raw []byte) error {
+	return spawnPaste(entry, string(raw), "-sequence", cfg.AutotypeSequence, "-name", entry)
+}
+
+// copyAndPaste spawns the paste process for the password of entry, or the
+// value of key if not empty, which copies it to the clipboard unless mode
+// types it. An empty mode only copies it.
+func copyAndPaste(s store.Store, entry, key string, mode autotype.Mode) error {
+	value, err := s.ShowKey(entry, key)
+	if err != nil {
+		return err
+	}
+	log.Printf("Retrieved %s for entry %s, spawning paste process", orPassword(key), entry)
+	flags := append(modeFlags(mode), "-name", secretName(entry, key))
+	return spawnPaste(entry, value, flags...)
+}
+
+// otpPeriods remembers the TOTP period of the entries whose code was computed,
+// to show how long codes remain valid without decrypting them when searching.
+var otpPeriods sync.Map
+
+// otpDescription describes the OTP search result of entry.
+func otpDescription(entry string) string {
+	period := otp.DefaultPeriod
+	if p, ok := otpPeriods.Load(entry); ok {
+		period = p.(int)
+	}
+	remaining := otp.Remaining(time.Now(), period).Round(time.Second)
+	return fmt.Sprintf("Copy OTP code to clipboard (%ds remaining)", int(remaining.Seconds()))
+}
+
+// copyOTP computes the current OTP code of entry and spawns the paste process for it.
+func copyOTP(s store.Store, entry string, mode autotype.Mode) error {
+	key, err := s.OTP(entry)
+	if err != nil {
+		return err
+	}
+	otpPeriods.Store(entry, key.Period)
+	code := key.Code(time.Now())
+	log.Printf("Computed OTP for entry %s, spawning paste process", entry)
+	return spawnPaste(entry, code, append(modeFlags(mode), "-name", "OTP code of "+entry)...)
+}
+
+// openURL opens url with the default browser.
+func openURL(entry, url string) error {
+	cmd := exec.Command("xdg-open", url)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("xdg-open start failed: %w", err)
+	}
+	log.Printf("Opened url of entry %s", entry)
+	return cmd.Process.Release()
+}
+
+// spawnPaste starts a detached '<self> paste' process with the given flags,
+// fed with secret on its stdin. It is replaced in tests.
+var spawnPaste = func(entry, secret string, flags ...string) error {
+	pasteCmd := exec.Command(os.Args[0], append(append([]string{"paste"}, pasteFlags()...), flags...)...)
+	pasteCmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+	pasteCmd.Stdin = strings.NewReader(secret)
+	pasteCmd.Stdout = nil
+	pasteCmd.Stderr = nil
+	if err := pasteCmd.Start(); err != nil {
+		return fmt.Errorf("'%s paste' start failed: %w", os.Args[0], err)
+	}
+	log.Printf("Started '%s paste' (pid %d) for entry %s", os.Args[0], pasteCmd.Process.Pid, entry)
+	return nil
+}
+
+// secretName describes the password of entry, or its key if not empty, in notifications.
+func secretName(entry, key string) string {
+	if key == "" {
+		return entry
+	}
+	return key + " of " + entry
+}
+
+func orPassword(key string) string {
+	if key == "" {
+		return "password"
+	}
+	return key
+}
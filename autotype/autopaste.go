@@ -2,25 +2,62 @@ package autotype
 
 import (
 	"fmt"
-	"time"
+	"strings"
+)
+
+// Mode selects how a secret gets into the focused window.
+type Mode string
 
-	"github.com/bendahl/uinput"
+const (
+	// ModePasteKey presses the dedicated Paste key.
+	ModePasteKey Mode = "paste-key"
+	// ModeCtrlV presses Ctrl+V.
+	ModeCtrlV Mode = "ctrl-v"
+	// ModeShiftInsert presses Shift+Insert, which terminals usually understand.
+	ModeShiftInsert Mode = "shift-insert"
+	// ModeType types the secret key by key instead of using the clipboard.
+	ModeType Mode = "type"
 )
 
-// PressPaste simulates the paste key being pressed by using a fake /dev/uinput device.
-// Tested on Wayland and working, your mileage may vary on other systems.
-func PressPaste() error {
-	keyboard, err := uinput.CreateKeyboard("/dev/uinput", []byte("gopasspasteplugin"))
-	if err != nil {
-		return fmt.Errorf("create virtual keyboard: %w", err)
-	}
-	defer keyboard.Close()
+// Modes lists all the supported modes.
+var Modes = []Mode{ModePasteKey, ModeCtrlV, ModeShiftInsert, ModeType}
 
-	if err := keyboard.KeyPress(uinput.KeyPaste); err != nil {
-		return fmt.Errorf("key press for paste: %w", err)
+// ParseMode returns the Mode named s.
+func ParseMode(s string) (Mode, error) {
+	for _, m := range Modes {
+		if string(m) == s {
+			return m, nil
+		}
 	}
+	names := make([]string, len(Modes))
+	for i, m := range Modes {
+		names[i] = string(m)
+	}
+	return "", fmt.Errorf("unknown paste mode %q, expected one of %s", s, strings.Join(names, ", "))
+}
 
-	// Give events time to be processed before destroying the device
-	time.Sleep(100 * time.Millisecond)
-	return nil
+// UsesClipboard reports whether the secret must be in the clipboard for the mode to work.
+func (m Mode) UsesClipboard() bool {
+	return m != ModeType
+}
+
+// UsesLayout reports whether the mode depends on the keyboard layout of the
+// user when sending key codes.
+func (m Mode) UsesLayout() bool {
+	return m == ModeType || m == ModeCtrlV
+}
+
+// Paste gets text into the focused window with t, using the given mode.
+func Paste(t Typer, mode Mode, text string) error {
+	switch mode {
+	case ModePasteKey:
+		return t.Press(KeyPaste, 0)
+	case ModeCtrlV:
+		return t.Press(KeyV, ModCtrl)
+	case ModeShiftInsert:
+		return t.Press(KeyInsert, ModShift)
+	case ModeType:
+		return t.Type(text)
+	}
+	return fmt.Errorf("unknown paste mode %q", mode)
 }
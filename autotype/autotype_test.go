@@ -0,0 +1,229 @@
+package autotype
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/autotype/xkb"
+)
+
+func TestUSKeymapCoversPrintableASCII(t *testing.T) {
+	for r := rune(0x20); r < 0x7f; r++ {
+		if _, ok := usKeymap[r]; !ok {
+			t.Errorf("character %q missing from the US keymap", r)
+		}
+	}
+	if usKeymap['a'].Code != usKeymap['A'].Code || usKeymap['a'].Mods != 0 || usKeymap['A'].Mods != ModShift {
+		t.Errorf("'a' and 'A' should share a key and differ by shift: %+v %+v", usKeymap['a'], usKeymap['A'])
+	}
+}
+
+func TestFromXKB(t *testing.T) {
+	km := FromXKB(xkb.Keymap{
+		'a': {Code: 16},
+		'A': {Code: 16, Level: xkb.LevelShift},
+		'@': {Code: 11, Level: xkb.LevelAltGr},
+		'¡': {Code: 2, Level: xkb.LevelShiftAltGr},
+	})
+	for r, want := range map[rune]Stroke{
+		'a':  {Code: 16},
+		'A':  {Code: 16, Mods: ModShift},
+		'@':  {Code: 11, Mods: ModAltGr},
+		'¡':  {Code: 2, Mods: ModShift | ModAltGr},
+		'\n': {Code: usKeymap['\n'].Code},
+	} {
+		if got := km[r]; got != want {
+			t.Errorf("km[%q] = %+v, want %+v", r, got, want)
+		}
+	}
+}
+
+func TestStrokesUnicodeFallback(t *testing.T) {
+	got, err := usKeymap.strokes("a€")
+	if err != nil {
+		t.Fatalf("strokes: %v", err)
+	}
+	want := []Stroke{
+		usKeymap['a'],
+		{Code: usKeymap['u'].Code, Mods: ModCtrl | ModShift},
+		usKeymap['2'], usKeymap['0'], usKeymap['a'], usKeymap['c'],
+		usKeymap[' '],
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("strokes = %+v, want %+v", got, want)
+	}
+
+	if _, err := (Keymap{'a': {Code: 30}}).strokes("€"); err == nil {
+		t.Error("strokes should fail without a U key to enter code points")
+	}
+}
+
+func TestStrokeRemapsCharacterKeys(t *testing.T) {
+	// on Dvorak, V is where the US layout has its dot key
+	dvorak := Keymap{'v': {Code: usKeymap['.'].Code}}
+	if got, want := dvorak.stroke(KeyV, ModCtrl), (Stroke{Code: usKeymap['.'].Code, Mods: ModCtrl}); got != want {
+		t.Errorf("stroke(V) = %+v, want %+v", got, want)
+	}
+	if got, want := dvorak.stroke(KeyInsert, ModShift), (Stroke{Code: KeyInsert.Code, Mods: ModShift}); got != want {
+		t.Errorf("stroke(Insert) = %+v, want %+v", got, want)
+	}
+}
+
+func TestPaste(t *testing.T) {
+	for mode, want := range map[Mode]string{
+		ModePasteKey:    "press XF86Paste",
+		ModeCtrlV:       "press ctrl+v",
+		ModeShiftInsert: "press shift+Insert",
+		ModeType:        "type s3cr3t",
+	} {
+		var f Fake
+		if err := Paste(&f, mode, "s3cr3t"); err != nil {
+			t.Errorf("Paste(%s): %v", mode, err)
+		}
+		if !reflect.DeepEqual(f.Events, []string{want}) {
+			t.Errorf("Paste(%s) events = %q, want %q", mode, f.Events, want)
+		}
+	}
+
+	if err := Paste(&Fake{}, "telepathy", "s3cr3t"); err == nil {
+		t.Error("Paste should reject unknown modes")
+	}
+}
+
+// events is a fake ydotoold socket, decoding the input events written to it.
+type events struct{ got []string }
+
+func (e *events) Write(p []byte) (int, error) {
+	typ := binary.NativeEndian.Uint16(p[inputEventSize-8:])
+	code := binary.NativeEndian.Uint16(p[inputEventSize-6:])
+	value := binary.NativeEndian.Uint32(p[inputEventSize-4:])
+	if typ == evKey {
+		e.got = append(e.got, fmt.Sprintf("%d:%d", code, value))
+	}
+	return len(p), nil
+}
+
+func (e *events) Close() error { return nil }
+
+func TestCommandTyper(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		do     func(Typer) error
+		wants  []string
+		stdin  string
+		events []string
+	}{
+		{"wtype", func(ty Typer) error { return ty.Type("pw") }, []string{"wtype -d 5 -"}, "pw", nil},
+		{"wtype", func(ty Typer) error { return ty.Press(KeyV, ModCtrl) }, []string{"wtype -M ctrl -k v -m ctrl"}, "", nil},
+		{"xdotool", func(ty Typer) error { return ty.Type("pw") }, []string{"xdotool type --clearmodifiers --delay 5 --file -"}, "pw", nil},
+		{"xdotool", func(ty Typer) error { return ty.Press(KeyInsert, ModShift) }, []string{"xdotool key --clearmodifiers shift+Insert"}, "", nil},
+		{"ydotool", func(ty Typer) error { return ty.Type("pW") }, nil, "", []string{"25:1", "25:0", "42:1", "17:1", "17:0", "42:0"}},
+		{"ydotool", func(ty Typer) error { return ty.Press(KeyPaste, 0) }, []string{"ydotool key 135:1 135:0"}, "", nil},
+	} {
+		var got []string
+		var stdin string
+		socket := &events{}
+		typer := &commandTyper{name: tc.name, km: usKeymap, run: func(in string, name string, args ...string) error {
+			got = append(got, strings.Join(append([]string{name}, args...), " "))
+			stdin += in
+			return nil
+		}, dial: func() (io.WriteCloser, error) { return socket, nil }}
+		typer.SetKeyDelay(keyDelay)
+		if err := tc.do(typer); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, tc.wants) || stdin != tc.stdin || !reflect.DeepEqual(socket.got, tc.events) {
+			t.Errorf("%s ran %q with input %q and sent %q, want %q with %q and %q", tc.name, got, stdin, socket.got, tc.wants, tc.stdin, tc.events)
+		}
+	}
+}
+
+func TestCommandTyperHidesText(t *testing.T) {
+	const secret = "s3cr3t-Pa55"
+	for _, name := range []string{"wtype", "xdotool", "ydotool"} {
+		var argv []string
+		typer := &commandTyper{name: name, km: usKeymap, run: func(_ string, name string, args ...string) error {
+			argv = append(argv, append([]string{name}, args...)...)
+			return nil
+		}, dial: func() (io.WriteCloser, error) { return &events{}, nil }}
+		if err := typer.Type(secret); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// the key codes typed by ydotool give the text away too
+		if strings.Contains(strings.Join(argv, " "), secret) || name == "ydotool" && len(argv) > 0 {
+			t.Errorf("%s was run with the typed text in its arguments: %q", name, argv)
+		}
+	}
+}
+
+func TestNewUnknownBackend(t *testing.T) {
+	if _, err := New("carrier-pigeon", usKeymap); err == nil {
+		t.Error("New should reject unknown backends")
+	}
+	if err := Available("carrier-pigeon"); err == nil {
+		t.Error("Available should reject unknown backends")
+	}
+}
+
+func TestYdotoolSocket(t *testing.T) {
+	t.Setenv("YDOTOOL_SOCKET", "")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if got := YdotoolSocket(); got != "/run/user/1000/.ydotool_socket" {
+		t.Errorf("YdotoolSocket = %q", got)
+	}
+
+	// ydotool can't type without its daemon
+	socket := filepath.Join(t.TempDir(), "ydotool.sock")
+	t.Setenv("YDOTOOL_SOCKET", socket)
+	if err := Available("ydotool"); err == nil || !strings.Contains(err.Error(), "ydotoold is not running") {
+		t.Errorf("Available without ydotoold = %v", err)
+	}
+	os.WriteFile(socket, nil, 0o600)
+	if err := Available("ydotool"); err == nil || !strings.Contains(err.Error(), "not the socket") {
+		t.Errorf("Available with a file instead of the socket = %v", err)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, m := range Modes {
+		got, err := ParseMode(string(m))
+		if err != nil || got != m {
+			t.Errorf("ParseMode(%q) = %q, %v", m, got, err)
+		}
+	}
+	if _, err := ParseMode("telepathy"); err == nil {
+		t.Error("ParseMode should reject unknown modes")
+	}
+	if ModeType.UsesClipboard() || !ModeCtrlV.UsesClipboard() {
+		t.Error("only the type mode should bypass the clipboard")
+	}
+}
+
+func TestYdotooldSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "ydotool.sock")
+	t.Setenv("YDOTOOL_SOCKET", socket)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	typer := &commandTyper{name: "ydotool", km: usKeymap, dial: dialYdotoold}
+	if err := typer.Type("a"); err != nil {
+		t.Fatalf("Type: %v", err)
+	}
+	// the press and the release of a, each followed by a report
+	buf := make([]byte, 64)
+	for i := range 4 {
+		n, err := conn.Read(buf)
+		if err != nil || n != inputEventSize {
+			t.Fatalf("event %d: read %d bytes, %v", i, n, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package autotype
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// commandTyper drives one of the wtype, ydotool or xdotool tools.
+//
+// The typed text is never passed as an argument, which any local user can
+// read in /proc while the tool runs: wtype and xdotool read it on their
+// stdin, and the key events of ydotool are sent to the socket of ydotoold.
+type commandTyper struct {
+	name  string
+	km    Keymap
+	delay time.Duration
+	// run runs the tool with args and stdin as its input.
+	run func(stdin string, name string, args ...string) error
+	// dial connects to the socket of ydotoold.
+	dial func() (io.WriteCloser, error)
+}
+
+func newCommand(name string, km Keymap) (Typer, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &commandTyper{name: name, km: km, delay: keyDelay, run: runCommand, dial: dialYdotoold}, nil
+}
+
+func runCommand(stdin string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (t *commandTyper) Name() string { return t.name }
+
+func (t *commandTyper) Check(text string) error {
+	if t.name != "ydotool" {
+		// wtype and xdotool handle any character themselves
+		return nil
+	}
+	_, err := t.km.strokes(text)
+	return err
+}
+
+func (t *commandTyper) Type(text string) error {
+	ms := strconv.Itoa(int(t.delay.Milliseconds()))
+	switch t.name {
+	case "wtype":
+		return t.run(text, "wtype", "-d", ms, "-")
+	case "xdotool":
+		return t.run(text, "xdotool", "type", "--clearmodifiers", "--delay", ms, "--file", "-")
+	}
+	// "ydotool type" assumes a US layout, so send the key codes of the user's layout
+	strokes, err := t.km.strokes(text)
+	if err != nil {
+		return err
+	}
+	return t.sendYdotoold(keyEvents(strokes...))
+}
+
+func (t *commandTyper) Press(key Key, mods Modifiers) error {
+	switch t.name {
+	case "wtype":
+		var args []string
+		for _, m := range mods.names() {
+			args = append(args, "-M", m)
+		}
+		args = append(args, "-k", key.Name)
+		for _, m := range mods.names() {
+			args = append(args, "-m", m)
+		}
+		return t.run("", "wtype", args...)
+	case "xdotool":
+		return t.run("", "xdotool", "key", "--clearmodifiers", strings.Join(append(mods.names(), key.Name), "+"))
+	}
+	var args []string
+	for _, ev := range keyEvents(t.km.stroke(key, mods)) {
+		args = append(args, ev.String())
+	}
+	return t.run("", "ydotool", append([]string{"key"}, args...)...)
+}
+
+func (t *commandTyper) SetKeyDelay(d time.Duration) { t.delay = d }
+
+func (t *commandTyper) Close() error { return nil }
+
+// keyEvent is a key press, or release when !down, of the key code.
+type keyEvent struct {
+	code int
+	down bool
+}
+
+// String returns the "code:1" or "code:0" argument of "ydotool key" for ev.
+func (ev keyEvent) String() string {
+	if ev.down {
+		return strconv.Itoa(ev.code) + ":1"
+	}
+	return strconv.Itoa(ev.code) + ":0"
+}
+
+// keyEvents returns the key events typing strokes.
+func keyEvents(strokes ...Stroke) []keyEvent {
+	var events []keyEvent
+	for _, st := range strokes {
+		mods := st.Mods.keys()
+		for _, m := range mods {
+			events = append(events, keyEvent{m, true})
+		}
+		events = append(events, keyEvent{st.Code, true}, keyEvent{st.Code, false})
+		for i := len(mods) - 1; i >= 0; i-- {
+			events = append(events, keyEvent{mods[i], false})
+		}
+	}
+	return events
+}
+
+// dialYdotoold connects to the datagram socket of ydotoold.
+func dialYdotoold() (io.WriteCloser, error) {
+	return net.Dial("unixgram", YdotoolSocket())
+}
+
+// inputEventSize is the size of a struct input_event, starting with a
+// struct timeval that ydotoold ignores.
+const inputEventSize = int(unsafe.Sizeof(syscall.Timeval{})) + 8
+
+// Event types and codes of the input events, from linux/input-event-codes.h.
+const (
+	evSyn     = 0x00
+	evKey     = 0x01
+	synReport = 0
+)
+
+// sendYdotoold sends events to ydotoold like "ydotool key" does: each one
+// as an input_event followed by a report, pausing for the key delay.
+func (t *commandTyper) sendYdotoold(events []keyEvent) error {
+	conn, err := t.dial()
+	if err != nil {
+		return fmt.Errorf("ydotool: connecting to ydotoold: %w", err)
+	}
+	defer conn.Close()
+	write := func(typ, code uint16, value int32) error {
+		ev := make([]byte, inputEventSize)
+		binary.NativeEndian.PutUint16(ev[inputEventSize-8:], typ)
+		binary.NativeEndian.PutUint16(ev[inputEventSize-6:], code)
+		binary.NativeEndian.PutUint32(ev[inputEventSize-4:], uint32(value))
+		_, err := conn.Write(ev)
+		return err
+	}
+	for _, ev := range events {
+		value := int32(0)
+		if ev.down {
+			value = 1
+		}
+		if err := write(evKey, uint16(ev.code), value); err != nil {
+			return fmt.Errorf("ydotool: %w", err)
+		}
+		if err := write(evSyn, synReport, 0); err != nil {
+			return fmt.Errorf("ydotool: %w", err)
+		}
+		time.Sleep(t.delay)
+	}
+	return nil
+}
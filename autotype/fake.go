@@ -0,0 +1,37 @@
+package autotype
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Fake is a Typer recording what it is asked to type, for tests.
+type Fake struct {
+	// Events holds one line per call, like "type hunter2", "press ctrl+v" or "delay 50ms".
+	Events []string
+	// Err, if set, is returned by Type and Press.
+	Err    error
+	Closed bool
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) Type(text string) error {
+	f.Events = append(f.Events, "type "+text)
+	return f.Err
+}
+
+func (f *Fake) Press(key Key, mods Modifiers) error {
+	f.Events = append(f.Events, "press "+strings.Join(append(mods.names(), key.Name), "+"))
+	return f.Err
+}
+
+func (f *Fake) SetKeyDelay(d time.Duration) {
+	f.Events = append(f.Events, fmt.Sprintf("delay %v", d))
+}
+
+func (f *Fake) Close() error {
+	f.Closed = true
+	return nil
+}
@@ -0,0 +1,173 @@
+package autotype
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/bendahl/uinput"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/autotype/xkb"
+)
+
+// Modifiers is a set of modifier keys held while pressing a key.
+type Modifiers uint8
+
+const (
+	ModShift Modifiers = 1 << iota
+	ModCtrl
+	// ModAltGr selects the third level of a key, e.g. '@' on AZERTY layouts.
+	ModAltGr
+)
+
+// keys returns the key codes of the modifiers in m.
+func (m Modifiers) keys() []int {
+	var keys []int
+	if m&ModCtrl != 0 {
+		keys = append(keys, uinput.KeyLeftctrl)
+	}
+	if m&ModShift != 0 {
+		keys = append(keys, uinput.KeyLeftshift)
+	}
+	if m&ModAltGr != 0 {
+		keys = append(keys, uinput.KeyRightalt)
+	}
+	return keys
+}
+
+// names returns the names of the modifiers in m, as understood by wtype and xdotool.
+func (m Modifiers) names() []string {
+	var names []string
+	if m&ModCtrl != 0 {
+		names = append(names, "ctrl")
+	}
+	if m&ModShift != 0 {
+		names = append(names, "shift")
+	}
+	if m&ModAltGr != 0 {
+		names = append(names, "altgr")
+	}
+	return names
+}
+
+// Stroke is a key press along with the modifiers to hold during it.
+type Stroke struct {
+	Code int
+	Mods Modifiers
+}
+
+// Keymap maps characters to the key strokes producing them on a keyboard layout.
+type Keymap map[rune]Stroke
+
+// USKeymap returns the keymap of the US layout.
+func USKeymap() Keymap {
+	return usKeymap
+}
+
+// LoadKeymap returns the keymap of the layout configured for the user, see
+// xkb.DetectLayout. It returns the US keymap along with the error if the
+// layout can't be loaded.
+func LoadKeymap() (Keymap, error) {
+	km, err := xkb.Load()
+	if err != nil {
+		return usKeymap, fmt.Errorf("loading keyboard layout: %w", err)
+	}
+	return FromXKB(km), nil
+}
+
+// FromXKB converts an XKB keymap, adding the whitespace characters that XKB
+// keymaps describe as function keys.
+func FromXKB(km xkb.Keymap) Keymap {
+	levels := [...]Modifiers{
+		xkb.LevelNone:       0,
+		xkb.LevelShift:      ModShift,
+		xkb.LevelAltGr:      ModAltGr,
+		xkb.LevelShiftAltGr: ModShift | ModAltGr,
+	}
+	m := Keymap{
+		'\t': {Code: uinput.KeyTab},
+		'\n': {Code: uinput.KeyEnter},
+	}
+	for r, k := range km {
+		m[r] = Stroke{Code: k.Code, Mods: levels[k.Level]}
+	}
+	return m
+}
+
+// stroke returns the key stroke pressing key with mods. Keys named after a
+// character, like KeyV, are looked up in the keymap.
+func (km Keymap) stroke(key Key, mods Modifiers) Stroke {
+	if r := []rune(key.Name); len(r) == 1 {
+		if st, ok := km[r[0]]; ok {
+			return Stroke{Code: st.Code, Mods: st.Mods | mods}
+		}
+	}
+	return Stroke{Code: key.Code, Mods: mods}
+}
+
+// strokes returns the key strokes typing s. Characters missing from the keymap
+// are entered with their code point after Ctrl+Shift+U, which GTK and IBus
+// based applications understand.
+func (km Keymap) strokes(s string) ([]Stroke, error) {
+	strokes := make([]Stroke, 0, len(s))
+	for _, r := range s {
+		if st, ok := km[r]; ok {
+			strokes = append(strokes, st)
+			continue
+		}
+		u, ok := km['u']
+		if !ok {
+			return nil, fmt.Errorf("character %q cannot be typed", r)
+		}
+		strokes = append(strokes, Stroke{Code: u.Code, Mods: u.Mods | ModCtrl | ModShift})
+		for _, h := range strconv.FormatInt(int64(r), 16) {
+			st, ok := km[h]
+			if !ok {
+				return nil, fmt.Errorf("character %q cannot be typed", r)
+			}
+			strokes = append(strokes, st)
+		}
+		space, ok := km[' ']
+		if !ok {
+			return nil, fmt.Errorf("character %q cannot be typed", r)
+		}
+		strokes = append(strokes, space)
+	}
+	return strokes, nil
+}
+
+// usKeymap maps the characters of a US keyboard to the keys producing them.
+var usKeymap = func() Keymap {
+	m := Keymap{
+		' ':  {Code: uinput.KeySpace},
+		'\t': {Code: uinput.KeyTab},
+		'\n': {Code: uinput.KeyEnter},
+	}
+	letters := []int{
+		uinput.KeyA, uinput.KeyB, uinput.KeyC, uinput.KeyD, uinput.KeyE, uinput.KeyF, uinput.KeyG,
+		uinput.KeyH, uinput.KeyI, uinput.KeyJ, uinput.KeyK, uinput.KeyL, uinput.KeyM, uinput.KeyN,
+		uinput.KeyO, uinput.KeyP, uinput.KeyQ, uinput.KeyR, uinput.KeyS, uinput.KeyT, uinput.KeyU,
+		uinput.KeyV, uinput.KeyW, uinput.KeyX, uinput.KeyY, uinput.KeyZ,
+	}
+	for i, code := range letters {
+		m['a'+rune(i)] = Stroke{Code: code}
+		m['A'+rune(i)] = Stroke{Code: code, Mods: ModShift}
+	}
+	// unshifted and shifted characters of the other keys
+	for _, k := range []struct {
+		code           int
+		plain, shifted rune
+	}{
+		{uinput.Key1, '1', '!'}, {uinput.Key2, '2', '@'}, {uinput.Key3, '3', '#'},
+		{uinput.Key4, '4', '$'}, {uinput.Key5, '5', '%'}, {uinput.Key6, '6', '^'},
+		{uinput.Key7, '7', '&'}, {uinput.Key8, '8', '*'}, {uinput.Key9, '9', '('},
+		{uinput.Key0, '0', ')'}, {uinput.KeyMinus, '-', '_'}, {uinput.KeyEqual, '=', '+'},
+		{uinput.KeyLeftbrace, '[', '{'}, {uinput.KeyRightbrace, ']', '}'},
+		{uinput.KeySemicolon, ';', ':'}, {uinput.KeyApostrophe, '\'', '"'},
+		{uinput.KeyGrave, '`', '~'}, {uinput.KeyBackslash, '\\', '|'},
+		{uinput.KeyComma, ',', '<'}, {uinput.KeyDot, '.', '>'}, {uinput.KeySlash, '/', '?'},
+	} {
+		m[k.plain] = Stroke{Code: k.code}
+		m[k.shifted] = Stroke{Code: k.code, Mods: ModShift}
+	}
+	return m
+}()
@@ -0,0 +1,194 @@
+package autotype
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSequence logs into a typical web form.
+const DefaultSequence = "{USERNAME}{TAB}{PASSWORD}{ENTER}"
+
+// StepKind tells what a Step of a sequence does.
+type StepKind int
+
+const (
+	// StepText types Step.Text literally.
+	StepText StepKind = iota
+	// StepField types the value of the secret field Step.Text.
+	StepField
+	// StepKey presses Step.Key Step.Repeat times.
+	StepKey
+	// StepDelay waits for Step.Delay.
+	StepDelay
+	// StepKeyDelay changes the pause between typed keys to Step.Delay.
+	StepKeyDelay
+)
+
+// Step is a single instruction of an autotype sequence.
+type Step struct {
+	Kind   StepKind
+	Text   string
+	Key    Key
+	Repeat int
+	Delay  time.Duration
+}
+
+// specialKeys are the key placeholders of sequences, named like in KeePass.
+var specialKeys = map[string]Key{
+	"TAB": KeyTab, "ENTER": KeyEnter, "SPACE": KeySpace,
+	"BACKSPACE": KeyBackspace, "BS": KeyBackspace, "BKSP": KeyBackspace,
+	"ESC": KeyEsc, "DELETE": KeyDelete, "DEL": KeyDelete,
+	"INSERT": KeyInsert, "INS": KeyInsert,
+	"UP": KeyUp, "DOWN": KeyDown, "LEFT": KeyLeft, "RIGHT": KeyRight,
+	"HOME": KeyHome, "END": KeyEnd, "PGUP": KeyPageUp, "PGDN": KeyPageDown,
+}
+
+// fieldPlaceholders are the placeholders standing for well known secret fields.
+var fieldPlaceholders = map[string]string{
+	"USERNAME": "username", "PASSWORD": "password", "URL": "url", "TOTP": "totp",
+}
+
+// ParseSequence parses a KeePass-style autotype sequence such as
+// "{USERNAME}{TAB}{PASSWORD}{ENTER}". It supports:
+//   - {USERNAME}, {PASSWORD}, {URL} and {TOTP}, and {S:key} for any other key of the secret,
+//   - special keys like {TAB}, {ENTER} or {UP}, optionally repeated with {TAB 3},
+//   - {DELAY 200} to wait 200ms and {DELAY=50} to wait 50ms between typed keys,
+//   - {{} and {}} for literal braces; any other text is typed as is.
+func ParseSequence(seq string) ([]Step, error) {
+	var steps []Step
+	for seq != "" {
+		open := strings.IndexByte(seq, '{')
+		if open < 0 {
+			steps = appendText(steps, seq)
+			break
+		}
+		steps = appendText(steps, seq[:open])
+		seq = seq[open+1:]
+
+		// "{}}" is a literal closing brace, so look for the end after its first character
+		end := strings.IndexByte(seq[min(1, len(seq)):], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated placeholder {%s", seq)
+		}
+		end += min(1, len(seq))
+		step, err := parsePlaceholder(seq[:end])
+		if err != nil {
+			return nil, err
+		}
+		if step.Kind == StepText {
+			steps = appendText(steps, step.Text)
+		} else {
+			steps = append(steps, step)
+		}
+		seq = seq[end+1:]
+	}
+	return steps, nil
+}
+
+func appendText(steps []Step, text string) []Step {
+	if text == "" {
+		return steps
+	}
+	if n := len(steps); n > 0 && steps[n-1].Kind == StepText {
+		steps[n-1].Text += text
+		return steps
+	}
+	return append(steps, Step{Kind: StepText, Text: text})
+}
+
+func parsePlaceholder(p string) (Step, error) {
+	if p == "{" || p == "}" {
+		return Step{Kind: StepText, Text: p}, nil
+	}
+	if name, ok := strings.CutPrefix(p, "S:"); ok {
+		if name == "" {
+			return Step{}, fmt.Errorf("empty field name in {%s}", p)
+		}
+		return Step{Kind: StepField, Text: strings.ToLower(name)}, nil
+	}
+
+	upper := strings.ToUpper(p)
+	if v, ok := strings.CutPrefix(upper, "DELAY="); ok {
+		ms, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || ms < 0 {
+			return Step{}, fmt.Errorf("invalid delay in {%s}", p)
+		}
+		return Step{Kind: StepKeyDelay, Delay: time.Duration(ms) * time.Millisecond}, nil
+	}
+
+	name, arg, hasArg := strings.Cut(upper, " ")
+	if name == "DELAY" {
+		ms, err := strconv.Atoi(strings.TrimSpace(arg))
+		if !hasArg || err != nil || ms < 0 {
+			return Step{}, fmt.Errorf("invalid delay in {%s}", p)
+		}
+		return Step{Kind: StepDelay, Delay: time.Duration(ms) * time.Millisecond}, nil
+	}
+	if field, ok := fieldPlaceholders[name]; ok && !hasArg {
+		return Step{Kind: StepField, Text: field}, nil
+	}
+	key, ok := specialKeys[name]
+	if !ok {
+		return Step{}, fmt.Errorf("unknown placeholder {%s}", p)
+	}
+	repeat := 1
+	if hasArg {
+		n, err := strconv.Atoi(strings.TrimSpace(arg))
+		if err != nil || n < 1 {
+			return Step{}, fmt.Errorf("invalid repeat count in {%s}", p)
+		}
+		repeat = n
+	}
+	return Step{Kind: StepKey, Key: key, Repeat: repeat}, nil
+}
+
+// RunSequence types the steps of a sequence with t. Fields are resolved with
+// lookup, and checked when t can tell what it's able to type, before anything
+// is typed so that a missing field doesn't leave a half filled form.
+func RunSequence(t Typer, steps []Step, lookup func(field string) (string, error)) error {
+	steps, err := resolve(t, steps, lookup)
+	if err != nil {
+		return err
+	}
+	for _, s := range steps {
+		switch s.Kind {
+		case StepText:
+			err = t.Type(s.Text)
+		case StepKey:
+			for i := 0; i < s.Repeat && err == nil; i++ {
+				err = t.Press(s.Key, 0)
+			}
+		case StepDelay:
+			time.Sleep(s.Delay)
+		case StepKeyDelay:
+			t.SetKeyDelay(s.Delay)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve replaces the fields of steps by their value as text steps.
+func resolve(t Typer, steps []Step, lookup func(field string) (string, error)) ([]Step, error) {
+	resolved := make([]Step, len(steps))
+	for i, s := range steps {
+		if s.Kind == StepField {
+			v, err := lookup(s.Text)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", s.Text, err)
+			}
+			s = Step{Kind: StepText, Text: v}
+		}
+		if c, ok := t.(checker); ok && s.Kind == StepText {
+			if err := c.Check(s.Text); err != nil {
+				return nil, err
+			}
+		}
+		resolved[i] = s
+	}
+	return resolved, nil
+}
@@ -0,0 +1,117 @@
+package autotype
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSequence(t *testing.T) {
+	for seq, want := range map[string][]Step{
+		DefaultSequence: {
+			{Kind: StepField, Text: "username"},
+			{Kind: StepKey, Key: KeyTab, Repeat: 1},
+			{Kind: StepField, Text: "password"},
+			{Kind: StepKey, Key: KeyEnter, Repeat: 1},
+		},
+		"{S:Email}{tab 2}{DELAY 250}{totp}": {
+			{Kind: StepField, Text: "email"},
+			{Kind: StepKey, Key: KeyTab, Repeat: 2},
+			{Kind: StepDelay, Delay: 250 * time.Millisecond},
+			{Kind: StepField, Text: "totp"},
+		},
+		"{DELAY=0}user {{}x{}} done": {
+			{Kind: StepKeyDelay, Delay: 0},
+			{Kind: StepText, Text: "user {x} done"},
+		},
+		"": nil,
+	} {
+		got, err := ParseSequence(seq)
+		if err != nil {
+			t.Errorf("ParseSequence(%q): %v", seq, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseSequence(%q) = %+v, want %+v", seq, got, want)
+		}
+	}
+}
+
+func TestParseSequenceErrors(t *testing.T) {
+	for _, seq := range []string{
+		"{USERNAME",
+		"{NOPE}",
+		"{S:}",
+		"{TAB 0}",
+		"{TAB x}",
+		"{DELAY}",
+		"{DELAY -5}",
+		"{DELAY=soon}",
+		"{PASSWORD 2}",
+	} {
+		if _, err := ParseSequence(seq); err == nil {
+			t.Errorf("ParseSequence(%q) should fail", seq)
+		}
+	}
+}
+
+func TestRunSequence(t *testing.T) {
+	steps, err := ParseSequence("{USERNAME}{TAB}{PASSWORD}{DELAY=20}{DELAY 1}!{ENTER 2}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]string{"username": "Me", "password": "a1"}
+	lookup := func(field string) (string, error) {
+		v, ok := fields[field]
+		if !ok {
+			return "", fmt.Errorf("not found")
+		}
+		return v, nil
+	}
+
+	var f Fake
+	if err := RunSequence(&f, steps, lookup); err != nil {
+		t.Fatalf("RunSequence: %v", err)
+	}
+	want := []string{
+		"type Me",
+		"press Tab",
+		"type a1",
+		"delay 20ms",
+		"type !",
+		"press Return",
+		"press Return",
+	}
+	if !reflect.DeepEqual(f.Events, want) {
+		t.Errorf("events = %q, want %q", f.Events, want)
+	}
+}
+
+func TestRunSequenceChecksBeforeTyping(t *testing.T) {
+	lookup := func(field string) (string, error) {
+		if field == "password" {
+			return "pw€", nil
+		}
+		return "", fmt.Errorf("not found")
+	}
+
+	var f Fake
+	steps, _ := ParseSequence("{PASSWORD}{S:pin}")
+	if err := RunSequence(&f, steps, lookup); err == nil {
+		t.Error("RunSequence should fail on a missing field")
+	}
+	if len(f.Events) != 0 {
+		t.Errorf("nothing should be typed on a missing field, got %q", f.Events)
+	}
+
+	// typers relying on a keymap refuse characters they can't type
+	ct := &commandTyper{name: "ydotool", km: Keymap{'p': {Code: 25}, 'w': {Code: 17}}, run: func(string, string, ...string) error {
+		t.Error("nothing should be typed on an untypable character")
+		return nil
+	}}
+	steps, _ = ParseSequence("{PASSWORD}")
+	if err := RunSequence(ct, steps, lookup); err == nil {
+		t.Error("RunSequence should fail on an untypable character")
+	}
+}
@@ -0,0 +1,154 @@
+package autotype
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bendahl/uinput"
+)
+
+// Typer sends keyboard input to the focused window.
+type Typer interface {
+	// Name returns the name of the backend, as accepted by New.
+	Name() string
+	// Type types text.
+	Type(text string) error
+	// Press presses key while holding mods.
+	Press(key Key, mods Modifiers) error
+	// SetKeyDelay changes the pause between two typed keys.
+	SetKeyDelay(d time.Duration)
+	// Close releases the resources of the typer once its input has been processed.
+	Close() error
+}
+
+// checker is implemented by the typers that can tell in advance whether some
+// text can be typed, that is the ones relying on a Keymap.
+type checker interface {
+	Check(text string) error
+}
+
+// Key is a key that isn't typed as part of a text, like Tab or Paste.
+type Key struct {
+	// Name is the XKB keysym name, used by the wtype and xdotool backends.
+	Name string
+	// Code is the evdev key code, used by the uinput and ydotool backends.
+	Code int
+}
+
+// String returns the name of k.
+func (k Key) String() string {
+	return k.Name
+}
+
+// Keys used by the paste modes and the autotype sequences.
+var (
+	KeyPaste     = Key{Name: "XF86Paste", Code: uinput.KeyPaste}
+	KeyInsert    = Key{Name: "Insert", Code: uinput.KeyInsert}
+	KeyTab       = Key{Name: "Tab", Code: uinput.KeyTab}
+	KeyEnter     = Key{Name: "Return", Code: uinput.KeyEnter}
+	KeySpace     = Key{Name: "space", Code: uinput.KeySpace}
+	KeyBackspace = Key{Name: "BackSpace", Code: uinput.KeyBackspace}
+	KeyEsc       = Key{Name: "Escape", Code: uinput.KeyEsc}
+	KeyDelete    = Key{Name: "Delete", Code: uinput.KeyDelete}
+	KeyUp        = Key{Name: "Up", Code: uinput.KeyUp}
+	KeyDown      = Key{Name: "Down", Code: uinput.KeyDown}
+	KeyLeft      = Key{Name: "Left", Code: uinput.KeyLeft}
+	KeyRight     = Key{Name: "Right", Code: uinput.KeyRight}
+	KeyHome      = Key{Name: "Home", Code: uinput.KeyHome}
+	KeyEnd       = Key{Name: "End", Code: uinput.KeyEnd}
+	KeyPageUp    = Key{Name: "Prior", Code: uinput.KeyPageup}
+	KeyPageDown  = Key{Name: "Next", Code: uinput.KeyPagedown}
+	// KeyV is remapped through the keymap by the backends using key codes,
+	// since its position depends on the layout.
+	KeyV = Key{Name: "v", Code: uinput.KeyV}
+)
+
+// Backends lists the names of the typer backends, in the order Detect tries them.
+var Backends = []string{"uinput", "wtype", "ydotool", "xdotool"}
+
+// New returns the typer backend called name, or the first one working on
+// this system for "auto". The keymap is used by the backends sending key
+// codes, the others rely on the layout known to the compositor.
+func New(name string, km Keymap) (Typer, error) {
+	switch name {
+	case "auto", "":
+		return Detect(km)
+	case "uinput":
+		return NewUinput(km)
+	case "wtype", "ydotool", "xdotool":
+		return newCommand(name, km)
+	}
+	return nil, fmt.Errorf("unknown typer %q, expected auto or one of %s", name, strings.Join(Backends, ", "))
+}
+
+// Detect returns the first backend that can work here: uinput when
+// /dev/uinput is writable, then wtype on Wayland, ydotool, and xdotool on X11.
+func Detect(km Keymap) (Typer, error) {
+	var errs []error
+	for _, name := range Backends {
+		if err := Available(name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		t, err := New(name, km)
+		if err == nil {
+			return t, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("no working typer: %w", errors.Join(errs...))
+}
+
+// YdotoolSocket returns the socket of the ydotoold daemon: $YDOTOOL_SOCKET,
+// or its default in $XDG_RUNTIME_DIR or /tmp.
+func YdotoolSocket() string {
+	if socket := os.Getenv("YDOTOOL_SOCKET"); socket != "" {
+		return socket
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, ".ydotool_socket")
+	}
+	return "/tmp/.ydotool_socket"
+}
+
+// Available checks whether the backend called name can be used in this session.
+func Available(name string) error {
+	switch name {
+	case "uinput":
+		f, err := os.OpenFile(uinputPath, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("uinput: %w", err)
+		}
+		return f.Close()
+	case "wtype":
+		if os.Getenv("WAYLAND_DISPLAY") == "" {
+			return fmt.Errorf("wtype: not in a Wayland session")
+		}
+	case "xdotool":
+		if os.Getenv("DISPLAY") == "" {
+			return fmt.Errorf("xdotool: not in an X11 session")
+		}
+	case "ydotool":
+		// ydotool only sends the key presses to its daemon
+		socket := YdotoolSocket()
+		info, err := os.Stat(socket)
+		if err != nil {
+			return fmt.Errorf("ydotool: ydotoold is not running: %w", err)
+		}
+		if info.Mode().Type() != fs.ModeSocket {
+			return fmt.Errorf("ydotool: %s is not the socket of ydotoold", socket)
+		}
+	default:
+		return fmt.Errorf("unknown typer %q", name)
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package autotype
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bendahl/uinput"
+)
+
+const uinputPath = "/dev/uinput"
+
+// keyDelay is the default pause between two typed keys, some applications drop keys sent faster.
+const keyDelay = 5 * time.Millisecond
+
+// uinputTyper types on a fake /dev/uinput keyboard.
+// Tested on Wayland and working, your mileage may vary on other systems.
+type uinputTyper struct {
+	kb    uinput.Keyboard
+	km    Keymap
+	delay time.Duration
+}
+
+// NewUinput creates a fake keyboard on /dev/uinput, which usually requires
+// the user to be in the input group or a udev rule granting access to it.
+func NewUinput(km Keymap) (Typer, error) {
+	kb, err := uinput.CreateKeyboard(uinputPath, []byte("gopasspasteplugin"))
+	if err != nil {
+		return nil, fmt.Errorf("create virtual keyboard: %w", err)
+	}
+	// Let the compositor pick up the new device, or the first keys get lost
+	time.Sleep(200 * time.Millisecond)
+	return &uinputTyper{kb: kb, km: km, delay: keyDelay}, nil
+}
+
+func (t *uinputTyper) Name() string { return "uinput" }
+
+func (t *uinputTyper) Check(text string) error {
+	_, err := t.km.strokes(text)
+	return err
+}
+
+func (t *uinputTyper) Type(text string) error {
+	strokes, err := t.km.strokes(text)
+	if err != nil {
+		return err
+	}
+	for _, st := range strokes {
+		if err := t.press(st); err != nil {
+			return err
+		}
+		time.Sleep(t.delay)
+	}
+	return nil
+}
+
+func (t *uinputTyper) Press(key Key, mods Modifiers) error {
+	return t.press(t.km.stroke(key, mods))
+}
+
+func (t *uinputTyper) SetKeyDelay(d time.Duration) { t.delay = d }
+
+func (t *uinputTyper) Close() error {
+	// Give events time to be processed before destroying the device
+	time.Sleep(100 * time.Millisecond)
+	return t.kb.Close()
+}
+
+// press presses st.Code while holding its modifiers.
+func (t *uinputTyper) press(st Stroke) error {
+	mods := st.Mods.keys()
+	for _, m := range mods {
+		if err := t.kb.KeyDown(m); err != nil {
+			return fmt.Errorf("modifier key down: %w", err)
+		}
+	}
+	pressErr := t.kb.KeyPress(st.Code)
+	for i := len(mods) - 1; i >= 0; i-- {
+		if err := t.kb.KeyUp(mods[i]); err != nil && pressErr == nil {
+			pressErr = fmt.Errorf("modifier key up: %w", err)
+		}
+	}
+	if pressErr != nil {
+		return fmt.Errorf("key press %d: %w", st.Code, pressErr)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package xkb
+
+import (
+	"strconv"
+	"strings"
+)
+
+// asciiKeysyms names the keysyms of the printable ASCII characters that are
+// not letters or digits, whose keysym name is the character itself.
+var asciiKeysyms = map[string]rune{
+	"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$',
+	"percent": '%', "ampersand": '&', "apostrophe": '\'', "quoteright": '\'',
+	"parenleft": '(', "parenright": ')', "asterisk": '*', "plus": '+', "comma": ',',
+	"minus": '-', "period": '.', "slash": '/', "colon": ':', "semicolon": ';',
+	"less": '<', "equal": '=', "greater": '>', "question": '?', "at": '@',
+	"bracketleft": '[', "backslash": '\\', "bracketright": ']', "asciicircum": '^',
+	"underscore": '_', "grave": '`', "quoteleft": '`', "braceleft": '{', "bar": '|',
+	"braceright": '}', "asciitilde": '~',
+}
+
+// latin1Keysyms names the keysyms 0xa0 to 0xff, which match their Unicode code point.
+var latin1Keysyms = strings.Fields(`
+	nobreakspace exclamdown cent sterling currency yen brokenbar section
+	diaeresis copyright ordfeminine guillemotleft notsign hyphen registered macron
+	degree plusminus twosuperior threesuperior acute mu paragraph periodcentered
+	cedilla onesuperior masculine guillemotright onequarter onehalf threequarters questiondown
+	Agrave Aacute Acircumflex Atilde Adiaeresis Aring AE Ccedilla
+	Egrave Eacute Ecircumflex Ediaeresis Igrave Iacute Icircumflex Idiaeresis
+	ETH Ntilde Ograve Oacute Ocircumflex Otilde Odiaeresis multiply
+	Oslash Ugrave Uacute Ucircumflex Udiaeresis Yacute THORN ssharp
+	agrave aacute acircumflex atilde adiaeresis aring ae ccedilla
+	egrave eacute ecircumflex ediaeresis igrave iacute icircumflex idiaeresis
+	eth ntilde ograve oacute ocircumflex otilde odiaeresis division
+	oslash ugrave uacute ucircumflex udiaeresis yacute thorn ydiaeresis`)
+
+// otherKeysyms holds the aliases and the common keysyms outside of Latin-1.
+var otherKeysyms = map[string]rune{
+	"guillemetleft": '«', "guillemetright": '»', "ordmasculine": 'º',
+	"Eth": 'Ð', "Thorn": 'Þ', "Ooblique": 'Ø', "ooblique": 'ø',
+	"EuroSign": '€', "OE": 'Œ', "oe": 'œ', "Ydiaeresis": 'Ÿ',
+	"Scaron": 'Š', "scaron": 'š', "Zcaron": 'Ž', "zcaron": 'ž',
+	"Ccaron": 'Č', "ccaron": 'č', "Ecaron": 'Ě', "ecaron": 'ě',
+	"Rcaron": 'Ř', "rcaron": 'ř', "Lstroke": 'Ł', "lstroke": 'ł',
+	"Aogonek": 'Ą', "aogonek": 'ą', "Eogonek": 'Ę', "eogonek": 'ę',
+	"Zabovedot": 'Ż', "zabovedot": 'ż', "Sacute": 'Ś', "sacute": 'ś',
+	"Zacute": 'Ź', "zacute": 'ź', "Cacute": 'Ć', "cacute": 'ć',
+	"Nacute": 'Ń', "nacute": 'ń', "Uring": 'Ů', "uring": 'ů',
+	"Odoubleacute": 'Ő', "odoubleacute": 'ő', "Udoubleacute": 'Ű', "udoubleacute": 'ű',
+	"idotless": 'ı', "Iabovedot": 'İ', "Gbreve": 'Ğ', "gbreve": 'ğ',
+	"Scedilla": 'Ş', "scedilla": 'ş',
+	"endash": '–', "emdash": '—', "ellipsis": '…', "enfilledcircbullet": '•',
+	"leftsinglequotemark": '‘', "rightsinglequotemark": '’',
+	"leftdoublequotemark": '“', "rightdoublequotemark": '”',
+	"singlelowquotemark": '‚', "doublelowquotemark": '„',
+	"dagger": '†', "doubledagger": '‡', "trademark": '™', "permille": '‰',
+	"leftarrow": '←', "uparrow": '↑', "rightarrow": '→', "downarrow": '↓',
+	"notequal": '≠', "lessthanequal": '≤', "greaterthanequal": '≥', "infinity": '∞',
+	"Greek_mu": 'μ', "Greek_pi": 'π', "Greek_OMEGA": 'Ω',
+}
+
+// keysymRune returns the character produced by the keysym called name.
+// Function keys, dead keys and modifiers produce no character.
+func keysymRune(name string) (rune, bool) {
+	if len(name) == 1 && name[0] > ' ' && name[0] < 0x7f {
+		// letters and digits are named after themselves
+		return rune(name[0]), true
+	}
+	if r, ok := asciiKeysyms[name]; ok {
+		return r, true
+	}
+	if r, ok := otherKeysyms[name]; ok {
+		return r, true
+	}
+	for i, n := range latin1Keysyms {
+		if n == name {
+			return rune(0xa0 + i), true
+		}
+	}
+	// Unicode keysyms are written either U20AC or 0x10020ac
+	if len(name) > 1 && name[0] == 'U' {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return rune(v), true
+		}
+	}
+	if strings.HasPrefix(name, "0x") {
+		if v, err := strconv.ParseUint(name[2:], 16, 32); err == nil && v&0xff000000 == 0x01000000 {
+			return rune(v & 0x00ffffff), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,202 @@
+// Package xkb reads compiled XKB keymaps, as printed by "xkbcli compile-keymap",
+// to find out which key and shift level produce each character.
+package xkb
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Level is the shift level of a key: which modifiers select a symbol.
+type Level int
+
+const (
+	// LevelNone needs no modifier.
+	LevelNone Level = iota
+	// LevelShift needs Shift.
+	LevelShift
+	// LevelAltGr needs AltGr, also known as ISO_Level3_Shift.
+	LevelAltGr
+	// LevelShiftAltGr needs both Shift and AltGr.
+	LevelShiftAltGr
+)
+
+// Key is a key press producing a character.
+type Key struct {
+	// Code is the evdev key code, which is the XKB key code minus 8.
+	Code  int
+	Level Level
+}
+
+// Keymap maps characters to the key press producing them.
+type Keymap map[rune]Key
+
+// Layout identifies an XKB layout and its variant, e.g. "fr" and "azerty".
+type Layout struct {
+	Name    string
+	Variant string
+}
+
+var (
+	keycodeRe = regexp.MustCompile(`<([^>]+)>\s*=\s*(\d+)\s*;`)
+	aliasRe   = regexp.MustCompile(`alias\s*<([^>]+)>\s*=\s*<([^>]+)>\s*;`)
+	keyRe     = regexp.MustCompile(`(?s)\bkey\s*<([^>]+)>\s*\{(.*?)\}\s*;`)
+	groupRe   = regexp.MustCompile(`(?i)symbols\[\s*(?:group)?1\s*\]\s*=\s*\[([^\]]*)\]`)
+	levelsRe  = regexp.MustCompile(`\[([^\]]*)\]`)
+)
+
+// Parse builds the keymap of the first group of a compiled XKB keymap.
+// It assumes the usual key types, where the second level is reached with
+// Shift, the third with AltGr and the fourth with both.
+func Parse(text string) (Keymap, error) {
+	keycodesText, err := section(text, "xkb_keycodes")
+	if err != nil {
+		return nil, err
+	}
+	symbolsText, err := section(text, "xkb_symbols")
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make(map[string]int)
+	for _, m := range keycodeRe.FindAllStringSubmatch(keycodesText, -1) {
+		code, _ := strconv.Atoi(m[2])
+		codes[m[1]] = code
+	}
+	for _, m := range aliasRe.FindAllStringSubmatch(keycodesText, -1) {
+		if code, ok := codes[m[2]]; ok {
+			codes[m[1]] = code
+		}
+	}
+
+	km := make(Keymap)
+	for _, m := range keyRe.FindAllStringSubmatch(symbolsText, -1) {
+		code, ok := codes[m[1]]
+		if !ok || code < 8 {
+			continue
+		}
+		var levels string
+		if g := groupRe.FindStringSubmatch(m[2]); g != nil {
+			levels = g[1]
+		} else if strings.Contains(m[2], "symbols[") {
+			// only other groups are defined for this key
+			continue
+		} else if l := levelsRe.FindStringSubmatch(m[2]); l != nil {
+			levels = l[1]
+		}
+		for i, sym := range strings.Split(levels, ",") {
+			if i > int(LevelShiftAltGr) {
+				break
+			}
+			r, ok := keysymRune(strings.TrimSpace(sym))
+			if !ok {
+				continue
+			}
+			key := Key{Code: code - 8, Level: Level(i)}
+			// prefer the key needing the fewest modifiers, then the lowest code
+			// so that main keys win over the keypad
+			if prev, ok := km[r]; !ok || key.Level < prev.Level || key.Level == prev.Level && key.Code < prev.Code {
+				km[r] = key
+			}
+		}
+	}
+	if len(km) == 0 {
+		return nil, fmt.Errorf("no symbols found in the keymap")
+	}
+	return km, nil
+}
+
+// section returns the content of the named section of a keymap.
+func section(text, name string) (string, error) {
+	start := strings.Index(text, name)
+	if start < 0 {
+		return "", fmt.Errorf("keymap has no %s section", name)
+	}
+	open := strings.IndexByte(text[start:], '{')
+	if open < 0 {
+		return "", fmt.Errorf("malformed %s section", name)
+	}
+	depth := 0
+	for i := start + open; i < len(text); i++ {
+		switch text[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return text[start+open+1 : i], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unterminated %s section", name)
+}
+
+// DetectLayout returns the layout of the user, from the XKB_DEFAULT_LAYOUT and
+// XKB_DEFAULT_VARIANT environment variables used by xkbcommon, or from the
+// system configuration reported by localectl. Only the first layout of a
+// comma separated list is used.
+func DetectLayout() (Layout, error) {
+	if name := os.Getenv("XKB_DEFAULT_LAYOUT"); name != "" {
+		return Layout{Name: first(name), Variant: first(os.Getenv("XKB_DEFAULT_VARIANT"))}, nil
+	}
+	out, err := exec.Command("localectl", "status").Output()
+	if err != nil {
+		return Layout{}, fmt.Errorf("localectl status: %w", err)
+	}
+	return parseLocalectl(string(out))
+}
+
+func parseLocalectl(out string) (Layout, error) {
+	var l Layout
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "X11 Layout":
+			l.Name = first(strings.TrimSpace(value))
+		case "X11 Variant":
+			l.Variant = first(strings.TrimSpace(value))
+		}
+	}
+	if l.Name == "" {
+		return Layout{}, fmt.Errorf("no X11 layout configured in localectl")
+	}
+	return l, nil
+}
+
+func first(list string) string {
+	name, _, _ := strings.Cut(list, ",")
+	return name
+}
+
+// Compile returns the compiled keymap text of layout using xkbcli.
+func Compile(layout Layout) (string, error) {
+	args := []string{"compile-keymap", "--layout", layout.Name}
+	if layout.Variant != "" {
+		args = append(args, "--variant", layout.Variant)
+	}
+	out, err := exec.Command("xkbcli", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("xkbcli compile-keymap: %w", err)
+	}
+	return string(out), nil
+}
+
+// Load detects, compiles and parses the keymap of the user.
+func Load() (Keymap, error) {
+	layout, err := DetectLayout()
+	if err != nil {
+		return nil, err
+	}
+	text, err := Compile(layout)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(text)
+}
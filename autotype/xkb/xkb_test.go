@@ -0,0 +1,145 @@
+package xkb
+
+import (
+	"testing"
+)
+
+// azerty is an excerpt of "xkbcli compile-keymap --layout fr" output.
+const azerty = `xkb_keymap {
+xkb_keycodes "evdev+aliases(azerty)" {
+	minimum = 8;
+	maximum = 255;
+	<ESC>                = 9;
+	<AE01>               = 10;
+	<AE02>               = 11;
+	<AE03>               = 12;
+	<AE05>               = 14;
+	<AD01>               = 24;
+	<AD06>               = 29;
+	<AD07>               = 30;
+	<AD11>               = 34;
+	<AC01>               = 38;
+	<AB04>               = 55;
+	<SPCE>               = 65;
+	<KP1>                = 87;
+	indicator 1 = "Caps Lock";
+	alias <LatQ>         = <AC01>;
+	alias <LatA>         = <AD01>;
+};
+
+xkb_types "complete" {
+	type "TWO_LEVEL" {
+		modifiers= Shift;
+		map[Shift]= 2;
+	};
+};
+
+xkb_symbols "pc+fr+inet(evdev)" {
+	name[Group1]="French";
+
+	key <ESC>                {	[          Escape ] };
+	key <AE01>               {	[       ampersand,               1,     onesuperior,      exclamdown ] };
+	key <AE02>               {	[          eacute,               2,      asciitilde,      oneeighth ] };
+	key <AE03>               {	[        quotedbl,               3,      numbersign,          sterling ] };
+	key <AE05>               {	[       parenleft,               5,     bracketleft,    U20AC ] };
+	key <LatA>               {
+		type= "FOUR_LEVEL_SEMIALPHABETIC",
+		symbols[Group1]= [               a,               A,              ae,              AE ]
+	};
+	key <AD06>               {	[               y,               Y ], [ Cyrillic_en, Cyrillic_EN ] };
+	key <AD07>               {	symbols[Group2]= [ Cyrillic_ghe, Cyrillic_GHE ] };
+	key <AD11>               {	[ dead_circumflex,  dead_diaeresis,      asciitilde ] };
+	key <LatQ>               {	[               q,               Q ] };
+	key <AB04>               {	[               v,               V ] };
+	key <SPCE>               {	[           space ] };
+	key <KP1>                {	[          KP_End,            KP_1 ] };
+	modifier_map Shift { <LFSH>, <RTSH> };
+};
+
+};
+`
+
+func TestParseAzerty(t *testing.T) {
+	km, err := Parse(azerty)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	for r, want := range map[rune]Key{
+		'&': {Code: 2, Level: LevelNone},
+		'1': {Code: 2, Level: LevelShift},
+		'¡': {Code: 2, Level: LevelShiftAltGr},
+		'é': {Code: 3, Level: LevelNone},
+		'2': {Code: 3, Level: LevelShift},
+		'#': {Code: 4, Level: LevelAltGr},
+		'€': {Code: 6, Level: LevelShiftAltGr},
+		'a': {Code: 16, Level: LevelNone},
+		'A': {Code: 16, Level: LevelShift},
+		'æ': {Code: 16, Level: LevelAltGr},
+		'y': {Code: 21, Level: LevelNone},
+		'q': {Code: 30, Level: LevelNone},
+		'v': {Code: 47, Level: LevelNone},
+		' ': {Code: 57, Level: LevelNone},
+		// both <AE02> and <AD11> produce a tilde on their third level, the lowest code wins
+		'~': {Code: 3, Level: LevelAltGr},
+	} {
+		if got, ok := km[r]; !ok || got != want {
+			t.Errorf("km[%q] = %+v, %v, want %+v", r, got, ok, want)
+		}
+	}
+
+	for _, r := range []rune{'^', 'г', 'н'} {
+		if got, ok := km[r]; ok {
+			t.Errorf("km[%q] = %+v, should not be typable", r, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, text := range map[string]string{
+		"no keycodes":  `xkb_keymap { xkb_symbols "x" { key <AE01> { [ 1 ] }; }; };`,
+		"no symbols":   `xkb_keymap { xkb_keycodes "x" { <AE01> = 10; }; };`,
+		"unterminated": `xkb_keymap { xkb_keycodes "x" { <AE01> = 10; }; xkb_symbols "x" { key <AE01> { [ 1 ] };`,
+		"empty":        `xkb_keymap { xkb_keycodes "x" { <AE01> = 10; }; xkb_symbols "x" { key <ESC> { [ Escape ] }; }; };`,
+	} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("%s: Parse should fail", name)
+		}
+	}
+}
+
+func TestKeysymRune(t *testing.T) {
+	for name, want := range map[string]rune{
+		"a": 'a', "Z": 'Z', "7": '7', "at": '@', "nobreakspace": ' ',
+		"eacute": 'é', "ydiaeresis": 'ÿ', "ssharp": 'ß', "EuroSign": '€',
+		"U20AC": '€', "0x10020ac": '€', "guillemetleft": '«',
+	} {
+		if got, ok := keysymRune(name); !ok || got != want {
+			t.Errorf("keysymRune(%q) = %q, %v, want %q", name, got, ok, want)
+		}
+	}
+	for _, name := range []string{"Escape", "dead_acute", "Shift_L", "KP_1", "NoSymbol", "0x20ac"} {
+		if got, ok := keysymRune(name); ok {
+			t.Errorf("keysymRune(%q) = %q, should produce nothing", name, got)
+		}
+	}
+}
+
+func TestParseLocalectl(t *testing.T) {
+	l, err := parseLocalectl(`   System Locale: LANG=fr_FR.UTF-8
+       VC Keymap: fr
+      X11 Layout: fr,us
+       X11 Model: pc105
+     X11 Variant: azerty,
+`)
+	if err != nil {
+		t.Fatalf("parseLocalectl: %v", err)
+	}
+	if l != (Layout{Name: "fr", Variant: "azerty"}) {
+		t.Errorf("layout = %+v", l)
+	}
+
+	if _, err := parseLocalectl("   System Locale: LANG=C\n"); err == nil {
+		t.Error("parseLocalectl without layout should fail")
+	}
+}
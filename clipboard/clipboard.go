@@ -0,0 +1,328 @@
+// Package clipboard reads and writes the system clipboard.
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Clipboard is a system clipboard.
+type Clipboard interface {
+	// Copy replaces the content of the clipboard with data. When sensitive is
+	// true, clipboard managers are asked not to keep it in their history.
+	Copy(data []byte, sensitive bool) error
+	// Paste returns the current content of the clipboard, which is empty if
+	// nothing was copied.
+	Paste() ([]byte, error)
+	// Clear empties the clipboard.
+	Clear() error
+}
+
+// TextType is the MIME type of the text copied by the clipboards.
+const TextType = "text/plain;charset=utf-8"
+
+// TypedClipboard is implemented by the clipboards that can hold content of
+// any MIME type, such as images, so that it can be restored as it was.
+type TypedClipboard interface {
+	// PasteTyped returns the current content of the clipboard and its MIME
+	// type, which are empty if nothing was copied.
+	PasteTyped() (data []byte, mimeType string, err error)
+	// CopyTyped replaces the content of the clipboard with data of mimeType.
+	CopyTyped(data []byte, mimeType string) error
+}
+
+// OnceCopier is implemented by the clipboards that can serve data a single time.
+type OnceCopier interface {
+	// CopyOnce copies data so that it can only be pasted once: the clipboard
+	// is emptied right after the first paste, which makes the Offer done.
+	CopyOnce(data []byte, sensitive bool) (*Offer, error)
+}
+
+// Offer is data served once by the clipboard.
+type Offer struct {
+	done   chan struct{}
+	once   sync.Once
+	revoke func() error
+}
+
+func newOffer(revoke func() error) *Offer {
+	return &Offer{done: make(chan struct{}), revoke: revoke}
+}
+
+// served marks the offer as done.
+func (o *Offer) served() {
+	o.once.Do(func() { close(o.done) })
+}
+
+// Done is closed once the data was pasted or revoked.
+func (o *Offer) Done() <-chan struct{} {
+	return o.done
+}
+
+// Revoke removes the data from the clipboard if it wasn't pasted yet.
+func (o *Offer) Revoke() error {
+	select {
+	case <-o.done:
+		return nil
+	default:
+	}
+	err := o.revoke()
+	o.served()
+	return err
+}
+
+// Detect returns the clipboard of the current session.
+func Detect() (Clipboard, error) {
+	if os.Getenv("WAYLAND_DISPLAY") == "" {
+		return nil, fmt.Errorf("not in a Wayland session")
+	}
+	for _, tool := range []string{"wl-copy", "wl-paste"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return nil, fmt.Errorf("%s not found, install wl-clipboard: %w", tool, err)
+		}
+	}
+	return NewWayland(), nil
+}
+
+// Wayland is the clipboard of a Wayland session, driven with the wl-copy and
+// wl-paste tools from wl-clipboard.
+type Wayland struct {
+	// run runs a wl-clipboard tool with stdin and returns its standard output.
+	run func(stdin []byte, name string, args ...string) ([]byte, error)
+	// start starts a wl-clipboard tool with stdin, returning a function to
+	// wait for it to exit and one to kill it.
+	start func(stdin []byte, name string, args ...string) (wait func() error, kill func() error, err error)
+
+	sensitiveOnce sync.Once
+	sensitiveFlag bool
+}
+
+// NewWayland returns the Wayland clipboard.
+func NewWayland() *Wayland {
+	return &Wayland{run: run, start: start}
+}
+
+func start(stdin []byte, name string, args ...string) (wait func() error, kill func() error, err error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+	return cmd.Wait, cmd.Process.Kill, nil
+}
+
+func run(stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Copy puts data in the clipboard as text. Marking it as sensitive requires
+// a wl-copy version supporting the --sensitive flag, older ones just copy it.
+func (w *Wayland) Copy(data []byte, sensitive bool) error {
+	_, err := w.run(data, "wl-copy", w.copyArgs(sensitive)...)
+	return err
+}
+
+// CopyOnce serves data from a wl-copy process running in the foreground with
+// --paste-once, which exits after the first paste. Revoking the offer kills
+// it, which withdraws the data from the clipboard.
+func (w *Wayland) CopyOnce(data []byte, sensitive bool) (*Offer, error) {
+	args := append([]string{"--foreground", "--paste-once"}, w.copyArgs(sensitive)...)
+	wait, kill, err := w.start(data, "wl-copy", args...)
+	if err != nil {
+		return nil, err
+	}
+	offer := newOffer(kill)
+	go func() {
+		wait()
+		offer.served()
+	}()
+	return offer, nil
+}
+
+func (w *Wayland) copyArgs(sensitive bool) []string {
+	args := []string{"--type", TextType}
+	if sensitive && w.supportsSensitive() {
+		args = append(args, "--sensitive")
+	}
+	return args
+}
+
+// supportsSensitive checks once whether wl-copy knows about --sensitive.
+func (w *Wayland) supportsSensitive() bool {
+	w.sensitiveOnce.Do(func() {
+		out, _ := w.run(nil, "wl-copy", "--help")
+		w.sensitiveFlag = bytes.Contains(out, []byte("--sensitive"))
+	})
+	return w.sensitiveFlag
+}
+
+func (w *Wayland) Paste() ([]byte, error) {
+	out, err := w.run(nil, "wl-paste", "--no-newline")
+	if err != nil {
+		if nothingCopied(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// nothingCopied reports whether wl-paste failed because the clipboard is empty.
+func nothingCopied(err error) bool {
+	return strings.Contains(err.Error(), "Nothing is copied") || strings.Contains(err.Error(), "No selection")
+}
+
+// PasteTyped returns the current content in the first MIME type listed by
+// wl-paste, or as plain text when it is offered, since applications usually
+// offer text in several types.
+func (w *Wayland) PasteTyped() ([]byte, string, error) {
+	out, err := w.run(nil, "wl-paste", "--list-types")
+	if err != nil {
+		if nothingCopied(err) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	types := strings.Fields(string(out))
+	if len(types) == 0 {
+		return nil, "", nil
+	}
+	mimeType := types[0]
+	for _, t := range types {
+		if t == TextType || t == "text/plain" {
+			mimeType = t
+			break
+		}
+	}
+	data, err := w.run(nil, "wl-paste", "--no-newline", "--type", mimeType)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, mimeType, nil
+}
+
+// CopyTyped puts data in the clipboard as mimeType.
+func (w *Wayland) CopyTyped(data []byte, mimeType string) error {
+	_, err := w.run(data, "wl-copy", "--type", mimeType)
+	return err
+}
+
+func (w *Wayland) Clear() error {
+	_, err := w.run(nil, "wl-copy", "--clear")
+	return err
+}
+
+// Fake is an in-memory Clipboard for tests. Data copied with CopyOnce is
+// removed by the first call to Paste.
+type Fake struct {
+	mu        sync.Mutex
+	data      []byte
+	mimeType  string
+	sensitive bool
+	offer     *Offer
+	// Err, if set, is returned by all the methods.
+	Err error
+}
+
+func (f *Fake) CopyOnce(data []byte, sensitive bool) (*Offer, error) {
+	if err := f.Copy(data, sensitive); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.offer = newOffer(func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.data, f.sensitive, f.offer = nil, false, nil
+		return nil
+	})
+	return f.offer, nil
+}
+
+func (f *Fake) Copy(data []byte, sensitive bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.data, f.mimeType, f.sensitive, f.offer = bytes.Clone(data), TextType, sensitive, nil
+	return nil
+}
+
+func (f *Fake) CopyTyped(data []byte, mimeType string) error {
+	if err := f.Copy(data, false); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mimeType = mimeType
+	return nil
+}
+
+func (f *Fake) PasteTyped() ([]byte, string, error) {
+	f.mu.Lock()
+	mimeType := f.mimeType
+	f.mu.Unlock()
+	data, err := f.Paste()
+	if data == nil {
+		mimeType = ""
+	}
+	return data, mimeType, err
+}
+
+func (f *Fake) Paste() ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data := bytes.Clone(f.data)
+	if f.offer != nil {
+		f.offer.served()
+		f.data, f.sensitive, f.offer = nil, false, nil
+	}
+	return data, f.Err
+}
+
+func (f *Fake) Clear() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.data, f.sensitive, f.offer = nil, false, nil
+	return nil
+}
+
+// Sensitive reports whether the current content was copied as sensitive.
+func (f *Fake) Sensitive() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sensitive
+}
+
+// ErrNotOurs is returned by ClearIf when the clipboard content changed
+// since it was copied, so it was left alone.
+var ErrNotOurs = errors.New("clipboard content changed, not clearing it")
+
+// ClearIf clears the clipboard if it still holds data.
+func ClearIf(c Clipboard, data []byte) error {
+	current, err := c.Paste()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, data) {
+		return ErrNotOurs
+	}
+	return c.Clear()
+}
@@ -0,0 +1,196 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeTools records the wl-clipboard invocations of a Wayland clipboard.
+type fakeTools struct {
+	calls     []string
+	help      string
+	clipboard []byte
+	// types are listed by wl-paste --list-types.
+	types string
+}
+
+func (f *fakeTools) run(stdin []byte, name string, args ...string) ([]byte, error) {
+	call := strings.Join(append([]string{name}, args...), " ")
+	f.calls = append(f.calls, call)
+	switch call {
+	case "wl-copy --help":
+		return []byte(f.help), nil
+	case "wl-copy --clear":
+		f.clipboard = nil
+	case "wl-paste --no-newline", "wl-paste --no-newline --type image/png", "wl-paste --no-newline --type text/plain;charset=utf-8":
+		if f.clipboard == nil {
+			return nil, fmt.Errorf("wl-paste failed: exit status 1: Nothing is copied")
+		}
+		return f.clipboard, nil
+	case "wl-paste --list-types":
+		if f.clipboard == nil {
+			return nil, fmt.Errorf("wl-paste failed: exit status 1: Nothing is copied")
+		}
+		return []byte(f.types), nil
+	default:
+		f.clipboard = stdin
+	}
+	return nil, nil
+}
+
+func TestWaylandCopyPasteClear(t *testing.T) {
+	tools := &fakeTools{help: "Usage:\n\twl-copy [options] text...\n"}
+	w := &Wayland{run: tools.run}
+
+	if err := w.Copy([]byte("hunter2"), true); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	got, err := w.Paste()
+	if err != nil || string(got) != "hunter2" {
+		t.Errorf("Paste() = %q, %v, want hunter2", got, err)
+	}
+	if err := w.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if got, err := w.Paste(); err != nil || got != nil {
+		t.Errorf("Paste() after Clear = %q, %v, want nothing", got, err)
+	}
+
+	want := []string{
+		"wl-copy --help",
+		"wl-copy --type text/plain;charset=utf-8",
+		"wl-paste --no-newline",
+		"wl-copy --clear",
+		"wl-paste --no-newline",
+	}
+	if !reflect.DeepEqual(tools.calls, want) {
+		t.Errorf("calls = %q, want %q", tools.calls, want)
+	}
+}
+
+func TestWaylandTyped(t *testing.T) {
+	tools := &fakeTools{}
+	w := &Wayland{run: tools.run}
+	if data, mimeType, err := w.PasteTyped(); err != nil || data != nil || mimeType != "" {
+		t.Errorf("PasteTyped of an empty clipboard = %q, %q, %v", data, mimeType, err)
+	}
+
+	if err := w.CopyTyped([]byte("\x89PNG"), "image/png"); err != nil {
+		t.Fatalf("CopyTyped: %v", err)
+	}
+	tools.types = "image/png\nimage/jpeg\n"
+	if data, mimeType, err := w.PasteTyped(); err != nil || string(data) != "\x89PNG" || mimeType != "image/png" {
+		t.Errorf("PasteTyped = %q, %q, %v", data, mimeType, err)
+	}
+	// text is restored as text rather than in the first type listed
+	tools.types = "text/html\nUTF8_STRING\ntext/plain;charset=utf-8\n"
+	if _, mimeType, err := w.PasteTyped(); err != nil || mimeType != TextType {
+		t.Errorf("PasteTyped of text = %q, %v", mimeType, err)
+	}
+}
+
+func TestWaylandCopySensitive(t *testing.T) {
+	tools := &fakeTools{help: "  -s, --sensitive  Hint that the data is sensitive\n"}
+	w := &Wayland{run: tools.run}
+
+	for range 2 {
+		if err := w.Copy([]byte("hunter2"), true); err != nil {
+			t.Fatalf("Copy: %v", err)
+		}
+	}
+	if err := w.Copy([]byte("hello"), false); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	want := []string{
+		"wl-copy --help",
+		"wl-copy --type text/plain;charset=utf-8 --sensitive",
+		"wl-copy --type text/plain;charset=utf-8 --sensitive",
+		"wl-copy --type text/plain;charset=utf-8",
+	}
+	if !reflect.DeepEqual(tools.calls, want) {
+		t.Errorf("calls = %q, want %q", tools.calls, want)
+	}
+}
+
+func TestWaylandCopyOnce(t *testing.T) {
+	tools := &fakeTools{}
+	exited := make(chan struct{})
+	var started string
+	w := &Wayland{run: tools.run, start: func(stdin []byte, name string, args ...string) (func() error, func() error, error) {
+		started = strings.Join(append([]string{name}, args...), " ") + " < " + string(stdin)
+		wait := func() error {
+			<-exited
+			return nil
+		}
+		kill := func() error {
+			close(exited)
+			return nil
+		}
+		return wait, kill, nil
+	}}
+
+	offer, err := w.CopyOnce([]byte("hunter2"), true)
+	if err != nil {
+		t.Fatalf("CopyOnce: %v", err)
+	}
+	if want := "wl-copy --foreground --paste-once --type text/plain;charset=utf-8 < hunter2"; started != want {
+		t.Errorf("started %q, want %q", started, want)
+	}
+
+	select {
+	case <-offer.Done():
+		t.Fatal("offer done before being pasted or revoked")
+	default:
+	}
+	if err := offer.Revoke(); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	<-offer.Done()
+	// revoking twice doesn't kill twice
+	if err := offer.Revoke(); err != nil {
+		t.Fatalf("second Revoke: %v", err)
+	}
+}
+
+func TestFakeCopyOnce(t *testing.T) {
+	var f Fake
+	offer, err := f.CopyOnce([]byte("hunter2"), true)
+	if err != nil {
+		t.Fatalf("CopyOnce: %v", err)
+	}
+	if got, _ := f.Paste(); string(got) != "hunter2" {
+		t.Errorf("first Paste() = %q, want hunter2", got)
+	}
+	<-offer.Done()
+	if got, _ := f.Paste(); got != nil {
+		t.Errorf("second Paste() = %q, want nothing", got)
+	}
+}
+
+func TestClearIf(t *testing.T) {
+	var f Fake
+	f.Copy([]byte("hunter2"), true)
+	if !f.Sensitive() {
+		t.Error("Fake should remember the sensitive hint")
+	}
+
+	f.Copy([]byte("something else"), false)
+	if err := ClearIf(&f, []byte("hunter2")); !errors.Is(err, ErrNotOurs) {
+		t.Errorf("ClearIf on changed content = %v, want ErrNotOurs", err)
+	}
+	if got, _ := f.Paste(); string(got) != "something else" {
+		t.Errorf("clipboard = %q, should be left alone", got)
+	}
+
+	f.Copy([]byte("hunter2"), true)
+	if err := ClearIf(&f, []byte("hunter2")); err != nil {
+		t.Errorf("ClearIf: %v", err)
+	}
+	if got, _ := f.Paste(); got != nil {
+		t.Errorf("clipboard = %q, want empty", got)
+	}
+}
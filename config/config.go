@@ -0,0 +1,322 @@
+// Package config loads the configuration of the plugin from
+// $XDG_CONFIG_HOME/cosmic-gopass-plugin/config.json.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/autotype"
+)
+
+// Actions are the possible default actions when activating an entry.
+var Actions = []string{"paste", "copy", "type", "autotype"}
+
+// Backends are the possible password stores, "auto" using gopass when it is
+// installed and pass otherwise.
+var Backends = []string{"auto", "gopass", "pass", "keepassxc"}
+
+// StoreBackend returns the backend of the store of c when it has no sources,
+// resolving "auto" to pass when gopassInstalled reports it isn't but
+// passStoreExists reports the store of pass is there. Otherwise "auto" is
+// gopass, which tells to install it when missing.
+func (c *Config) StoreBackend(gopassInstalled, passStoreExists func() bool) string {
+	if c.Backend != "auto" {
+		return c.Backend
+	}
+	if !gopassInstalled() && passStoreExists() {
+		return "pass"
+	}
+	return "gopass"
+}
+
+// SourceBackends are the possible backends of the sources, which name their
+// store explicitly.
+var SourceBackends = []string{"gopass", "pass", "keepassxc"}
+
+// Store configures the password store of a backend.
+type Store struct {
+	// PasswordStoreDir is the directory of the store of pass, the default
+	// of pass when empty.
+	PasswordStoreDir string `json:"password_store_dir"`
+	// KeePassXCDatabase is the .kdbx database of the keepassxc backend.
+	KeePassXCDatabase string `json:"keepassxc_database"`
+	// KeePassXCKeyFile is the key file unlocking the KeePassXC database.
+	KeePassXCKeyFile string `json:"keepassxc_key_file"`
+	// KeePassXCPasswordCommand is a shell command printing the password of
+	// the KeePassXC database, which only needs its key file when empty.
+	KeePassXCPasswordCommand string `json:"keepassxc_password_command"`
+	// KeePassXCRecycleBin is the name of the recycle bin group of the
+	// KeePassXC database, whose entries aren't listed, "Recycle Bin" when
+	// empty.
+	KeePassXCRecycleBin string `json:"keepassxc_recycle_bin"`
+}
+
+// validate reports the missing settings of the store of backend.
+func (s *Store) validate(backend string) []error {
+	if backend != "keepassxc" {
+		return nil
+	}
+	var errs []error
+	if s.KeePassXCDatabase == "" {
+		errs = append(errs, errors.New("keepassxc_database must be set for the keepassxc backend"))
+	}
+	if s.KeePassXCKeyFile == "" && s.KeePassXCPasswordCommand == "" {
+		errs = append(errs, errors.New("keepassxc_key_file or keepassxc_password_command must be set to unlock the database"))
+	}
+	return errs
+}
+
+// Source is a store searched along with the other sources, its entries
+// listed in a folder named after it.
+type Source struct {
+	// Name is the folder of the entries of the source, and their label.
+	Name string `json:"name"`
+	// Backend is the password store of the source, one of SourceBackends.
+	Backend string `json:"backend"`
+	// Disabled sources are not searched.
+	Disabled bool `json:"disabled"`
+	Store
+}
+
+// Config is the configuration of the plugin. Fields missing from the file
+// keep their default value.
+type Config struct {
+	// Prefix is the launcher query prefix of password searches.
+	Prefix string `json:"prefix"`
+	// OTPPrefix is the launcher query prefix of OTP searches.
+	OTPPrefix string `json:"otp_prefix"`
+	// MaxResults is the maximum number of results shown for a search.
+	MaxResults int `json:"max_results"`
+	// Description is shown below each entry in the results.
+	Description string `json:"description"`
+	// Icon is the icon name of the results.
+	Icon string `json:"icon"`
+	// Backend is the password store the entries come from, one of Backends.
+	Backend string `json:"backend"`
+	// Store configures the pass and keepassxc backends.
+	Store
+	// Sources are the stores searched together instead of Backend when set.
+	Sources []Source `json:"sources"`
+	// Gopass is the path of the gopass binary, found automatically when empty.
+	Gopass string `json:"gopass"`
+	// Sync lets gopass synchronise the store with its remotes, which can be slow.
+	Sync bool `json:"sync"`
+	// DefaultAction is what activating an entry does, one of Actions.
+	DefaultAction string `json:"default_action"`
+	// PasteMode is how the secret is pasted, see autotype.Mode.
+	PasteMode string `json:"paste_mode"`
+	// Typer is the backend sending key presses, see autotype.New.
+	Typer string `json:"typer"`
+	// AutotypeSequence is the default sequence of the autotype action.
+	AutotypeSequence string `json:"autotype_sequence"`
+	// ClearAfter is how long secrets stay in the clipboard.
+	ClearAfter Duration `json:"clear_after"`
+	// RestoreClipboard puts back the previous clipboard content after pasting.
+	RestoreClipboard bool `json:"restore_clipboard"`
+	// PasteOnce removes secrets from the clipboard once they are pasted.
+	PasteOnce bool `json:"paste_once"`
+	// Notify shows desktop notifications when secrets are copied and cleared,
+	// and when pasting fails.
+	Notify bool `json:"notify"`
+}
+
+// Default returns the default configuration.
+func Default() *Config {
+	return &Config{
+		Prefix:           "gp ",
+		OTPPrefix:        "otp ",
+		MaxResults:       19,
+		Description:      "Copy password to clipboard",
+		Icon:             "dialog-password",
+		Backend:          "auto",
+		DefaultAction:    "paste",
+		PasteMode:        string(autotype.ModePasteKey),
+		Typer:            "auto",
+		AutotypeSequence: autotype.DefaultSequence,
+		ClearAfter:       Duration(45 * time.Second),
+		Notify:           true,
+	}
+}
+
+// Path returns the location of the configuration file.
+func Path() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("locating the config directory: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "cosmic-gopass-plugin", "config.json"), nil
+}
+
+// Load reads and validates the configuration file, returning the default
+// configuration when there is none.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return Default(), err
+	}
+	return LoadFile(path)
+}
+
+// LoadFile reads and validates the configuration file at path, returning the
+// default configuration when it doesn't exist. On error, the default
+// configuration is returned along with it.
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return Default(), fmt.Errorf("reading config: %w", err)
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return Default(), fmt.Errorf("config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse parses and validates a JSON configuration.
+func Parse(data []byte) (*Config, error) {
+	c := Default()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, col := position(data, syntaxErr.Offset)
+			return nil, fmt.Errorf("line %d, column %d: %w", line, col, err)
+		}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("%s must be a %s, not %s", typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// position returns the line and column of the last byte read by the JSON
+// decoder when it reported an error after offset bytes.
+func position(data []byte, offset int64) (line, col int) {
+	before := data[:min(max(int(offset)-1, 0), len(data))]
+	line = bytes.Count(before, []byte{'\n'}) + 1
+	col = len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// Validate reports all the invalid settings of c.
+func (c *Config) Validate() error {
+	var errs []error
+	if strings.TrimSpace(c.Prefix) == "" {
+		errs = append(errs, errors.New("prefix must not be empty"))
+	}
+	if strings.TrimSpace(c.OTPPrefix) == "" {
+		errs = append(errs, errors.New("otp_prefix must not be empty"))
+	}
+	if c.OTPPrefix == c.Prefix {
+		errs = append(errs, errors.New("otp_prefix must differ from prefix"))
+	}
+	if c.MaxResults < 1 {
+		errs = append(errs, fmt.Errorf("max_results must be positive, not %d", c.MaxResults))
+	}
+	if !slices.Contains(Backends, c.Backend) {
+		errs = append(errs, fmt.Errorf("backend %q must be one of %s", c.Backend, strings.Join(Backends, ", ")))
+	}
+	if len(c.Sources) == 0 {
+		errs = append(errs, c.Store.validate(c.Backend)...)
+	} else {
+		errs = append(errs, c.validateSources()...)
+	}
+	if !slices.Contains(Actions, c.DefaultAction) {
+		errs = append(errs, fmt.Errorf("default_action %q must be one of %s", c.DefaultAction, strings.Join(Actions, ", ")))
+	}
+	if _, err := autotype.ParseMode(c.PasteMode); err != nil {
+		errs = append(errs, fmt.Errorf("paste_mode: %w", err))
+	}
+	if c.Typer != "auto" && !slices.Contains(autotype.Backends, c.Typer) {
+		errs = append(errs, fmt.Errorf("typer %q must be auto or one of %s", c.Typer, strings.Join(autotype.Backends, ", ")))
+	}
+	if _, err := autotype.ParseSequence(c.AutotypeSequence); err != nil {
+		errs = append(errs, fmt.Errorf("autotype_sequence: %w", err))
+	}
+	if c.ClearAfter <= 0 {
+		errs = append(errs, fmt.Errorf("clear_after must be positive, not %s", c.ClearAfter))
+	}
+	return errors.Join(errs...)
+}
+
+// validateSources reports the invalid sources, which must have distinct
+// names that can be used as folders. Only one enabled source can use gopass,
+// which has no settings of its own and already searches its mounted stores.
+func (c *Config) validateSources() []error {
+	var errs []error
+	names := make(map[string]bool)
+	enabled, gopass := 0, 0
+	for i, src := range c.Sources {
+		name := strings.ToLower(src.Name)
+		switch {
+		case name == "" || strings.ContainsAny(name, "/:@ \t") || strings.HasPrefix(name, "."):
+			errs = append(errs, fmt.Errorf("sources[%d]: name %q must be a folder name", i, src.Name))
+		case names[name]:
+			errs = append(errs, fmt.Errorf("sources[%d]: name %q is used by another source", i, src.Name))
+		}
+		names[name] = true
+		if !slices.Contains(SourceBackends, src.Backend) {
+			errs = append(errs, fmt.Errorf("sources[%d]: backend %q must be one of %s", i, src.Backend, strings.Join(SourceBackends, ", ")))
+		}
+		for _, err := range src.Store.validate(src.Backend) {
+			errs = append(errs, fmt.Errorf("sources[%d]: %w", i, err))
+		}
+		if !src.Disabled {
+			enabled++
+		}
+		if !src.Disabled && src.Backend == "gopass" {
+			if gopass++; gopass > 1 {
+				errs = append(errs, fmt.Errorf("sources[%d]: only one source can use gopass, whose mounted stores are all searched", i))
+			}
+		}
+	}
+	if enabled == 0 {
+		errs = append(errs, errors.New("sources are all disabled"))
+	}
+	return errs
+}
+
+// Duration is a time.Duration written like "45s" or "2m" in the configuration.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations must be strings like \"45s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
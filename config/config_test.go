@@ -0,0 +1,132 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("default config is invalid: %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	c, err := Parse([]byte(`{
+		"prefix": "pw ",
+		"max_results": 5,
+		"sync": true,
+		"backend": "pass",
+		"password_store_dir": "/srv/pass",
+		"default_action": "autotype",
+		"paste_mode": "ctrl-v",
+		"typer": "wtype",
+		"clear_after": "10s",
+		"paste_once": true,
+		"notify": false
+	}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if c.Prefix != "pw " || c.MaxResults != 5 || !c.Sync || c.Backend != "pass" || c.PasswordStoreDir != "/srv/pass" || c.DefaultAction != "autotype" ||
+		c.PasteMode != "ctrl-v" || c.Typer != "wtype" || c.ClearAfter != Duration(10*time.Second) || !c.PasteOnce || c.Notify {
+		t.Errorf("unexpected config %+v", c)
+	}
+	// unset fields keep their default
+	if d := Default(); c.OTPPrefix != d.OTPPrefix || c.Icon != d.Icon || c.Description != d.Description {
+		t.Errorf("defaults not kept: %+v", c)
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	c, err := Parse([]byte(`{
+		"sources": [
+			{"name": "personal", "backend": "gopass"},
+			{"name": "work", "backend": "keepassxc", "keepassxc_database": "/home/me/work.kdbx", "keepassxc_key_file": "/home/me/work.keyx", "keepassxc_recycle_bin": "Corbeille"},
+			{"name": "old", "backend": "pass", "password_store_dir": "/srv/pass", "disabled": true}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []Source{
+		{Name: "personal", Backend: "gopass"},
+		{Name: "work", Backend: "keepassxc", Store: Store{KeePassXCDatabase: "/home/me/work.kdbx", KeePassXCKeyFile: "/home/me/work.keyx", KeePassXCRecycleBin: "Corbeille"}},
+		{Name: "old", Backend: "pass", Disabled: true, Store: Store{PasswordStoreDir: "/srv/pass"}},
+	}
+	if !reflect.DeepEqual(c.Sources, want) {
+		t.Errorf("sources = %+v, want %+v", c.Sources, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		json string
+		want []string
+	}{
+		"syntax":        {"{\n  \"prefix\": \"gp \",\n  oops\n}", []string{"line 3, column 3"}},
+		"unknown field": {`{"max_result": 3}`, []string{`unknown field "max_result"`}},
+		"wrong type":    {`{"max_results": "3"}`, []string{"max_results must be a int"}},
+		"bad duration":  {`{"clear_after": "soon"}`, []string{"soon"}},
+		"invalid values": {
+			`{"prefix": "", "max_results": 0, "backend": "keyring", "default_action": "dance", "paste_mode": "telepathy", "typer": "pigeon", "autotype_sequence": "{NOPE}", "clear_after": "-1s"}`,
+			[]string{"prefix must not be empty", "max_results must be positive", `backend "keyring"`, `default_action "dance"`, "paste_mode", `typer "pigeon"`, "autotype_sequence", "clear_after must be positive"},
+		},
+		"same prefixes": {`{"prefix": "otp "}`, []string{"otp_prefix must differ"}},
+		"keepassxc":     {`{"backend": "keepassxc"}`, []string{"keepassxc_database must be set", "keepassxc_key_file or keepassxc_password_command"}},
+		"gopass sources": {
+			`{"sources": [{"name": "a", "backend": "gopass"}, {"name": "b", "backend": "gopass", "disabled": true}, {"name": "c", "backend": "gopass"}]}`,
+			[]string{"sources[2]: only one source can use gopass"},
+		},
+		"sources": {
+			`{"sources": [{"name": "a/b", "backend": "gopass", "disabled": true}, {"name": "x", "backend": "auto", "disabled": true}, {"name": "X", "backend": "keepassxc", "disabled": true}]}`,
+			[]string{`sources[0]: name "a/b"`, `sources[1]: backend "auto"`, `sources[2]: name "X" is used`, "sources[2]: keepassxc_database", "sources are all disabled"},
+		},
+	} {
+		_, err := Parse([]byte(tc.json))
+		if err == nil {
+			t.Errorf("%s: Parse should fail", name)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q should mention %q", name, err, want)
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	c, err := Load()
+	if err != nil {
+		t.Fatalf("Load without file: %v", err)
+	}
+	if !reflect.DeepEqual(c, Default()) {
+		t.Errorf("Load without file = %+v, want defaults", c)
+	}
+
+	path := filepath.Join(dir, "cosmic-gopass-plugin", "config.json")
+	if p, _ := Path(); p != path {
+		t.Errorf("Path() = %q, want %q", p, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"max_results": -1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err = Load()
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("Load error = %v, should name the file", err)
+	}
+	if !reflect.DeepEqual(c, Default()) {
+		t.Errorf("Load should fall back to the defaults on error, got %+v", c)
+	}
+}
@@ -0,0 +1,536 @@
+// Package doctor diagnoses the setup of the plugin, from finding gopass to the
+// launcher sending it queries, and suggests how to fix what doesn't work.
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/autotype"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/clipboard"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/config"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/install"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/store"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	OK      Status = "ok"
+	Warning Status = "warning"
+	Error   Status = "error"
+	// Skipped checks depend on a check that failed.
+	Skipped Status = "skipped"
+)
+
+// Result is the outcome of one check, with how to fix it when it failed.
+type Result struct {
+	Check  string `json:"check"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// commandTimeout bounds each command run by the checks, in case gopass waits
+// for some input.
+const commandTimeout = 10 * time.Second
+
+// Env is what the checks inspect, replaced in tests.
+type Env struct {
+	// Config is the loaded configuration, and ConfigErr the error loading it.
+	Config     *config.Config
+	ConfigErr  error
+	ConfigPath string
+	// Gopass is the gopass binary the plugin runs.
+	Gopass string
+	// PluginDirs are where pop-launcher looks for the plugin, by precedence.
+	PluginDirs []string
+
+	// Command runs a command without input and returns its standard
+	// output, followed by its standard error when it fails.
+	Command  func(name string, args ...string) ([]byte, error)
+	LookPath func(file string) (string, error)
+	Getenv   func(key string) string
+	Stat     func(name string) (fs.FileInfo, error)
+	// Typer reports whether a typer backend is usable, see autotype.Available.
+	Typer func(name string) error
+	// Clipboard reports whether the clipboard can be used.
+	Clipboard func() error
+}
+
+// System returns the environment of the current session, for the plugin
+// configured by cfg running the gopass binary at gopass.
+func System(cfg *config.Config, cfgErr error, gopass string) *Env {
+	cfgPath, err := config.Path()
+	if err != nil {
+		cfgPath = "the configuration file"
+	}
+	var dirs []string
+	if dir, err := install.Dir("", false); err == nil {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, filepath.Join("/etc", "pop-launcher", "plugins", install.PluginName))
+	if dir, err := install.Dir("", true); err == nil {
+		dirs = append(dirs, dir)
+	}
+	return &Env{
+		Config:     cfg,
+		ConfigErr:  cfgErr,
+		ConfigPath: cfgPath,
+		Gopass:     gopass,
+		PluginDirs: dirs,
+		Command: func(name string, args ...string) ([]byte, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+			defer cancel()
+			cmd := exec.CommandContext(ctx, name, args...)
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout, cmd.Stderr = &stdout, &stderr
+			if err := cmd.Run(); err != nil {
+				// the error is explained on stderr
+				return append(stdout.Bytes(), stderr.Bytes()...), err
+			}
+			return stdout.Bytes(), nil
+		},
+		LookPath: exec.LookPath,
+		Getenv:   os.Getenv,
+		Stat:     os.Stat,
+		Typer:    autotype.Available,
+		Clipboard: func() error {
+			_, err := clipboard.Detect()
+			return err
+		},
+	}
+}
+
+// Run runs all the checks in env.
+func Run(env *Env) []Result {
+	var results []Result
+	add := func(r Result) { results = append(results, r) }
+
+	add(checkConfig(env))
+	stores := usedStores(env)
+	if reason := withoutGopass(env.Config, stores); reason != "" {
+		for _, check := range []string{"gopass", "gopass version", "gopass ls"} {
+			add(Result{Check: check, Status: Skipped, Detail: reason})
+		}
+	} else if gopass := checkGopass(env); gopass.Status == Error {
+		add(gopass)
+		for _, check := range []string{"gopass version", "gopass ls"} {
+			add(Result{Check: check, Status: Skipped, Detail: "gopass not found"})
+		}
+	} else {
+		add(gopass)
+		add(checkGopassVersion(env))
+		add(checkGopassList(env))
+	}
+	if passStores := usedBy(stores, "pass"); len(passStores) > 0 {
+		add(checkGPG(env))
+		for _, s := range passStores {
+			add(checkPassStore(env, s))
+		}
+	}
+	if keePassStores := usedBy(stores, "keepassxc"); len(keePassStores) > 0 {
+		add(checkKeePassXCCLI(env))
+		for _, s := range keePassStores {
+			add(checkKeePassXCFile(env, "keepassxc database", "keepassxc_database", s.KeePassXCDatabase))
+			if s.KeePassXCKeyFile != "" {
+				add(checkKeePassXCFile(env, "keepassxc key file", "keepassxc_key_file", s.KeePassXCKeyFile))
+			}
+			if s.KeePassXCPasswordCommand != "" {
+				add(checkKeePassXCPassword(env, s.KeePassXCPasswordCommand))
+			}
+		}
+	}
+	add(checkCrypto(env))
+	add(checkUinput(env))
+	add(checkTyper(env))
+	add(checkClipboard(env))
+	plugin, dir := checkPlugin(env)
+	add(plugin)
+	if plugin.Status == Error {
+		add(Result{Check: "prefix", Status: Skipped, Detail: "plugin not installed"})
+	} else {
+		add(checkPrefix(env, dir))
+	}
+	return results
+}
+
+// Failed reports whether any check failed.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText writes results in a human readable form.
+func WriteText(w io.Writer, results []Result) error {
+	for _, r := range results {
+		if _, err := fmt.Fprintf(w, "[%s] %s: %s\n", r.Status, r.Check, r.Detail); err != nil {
+			return err
+		}
+		if r.Fix != "" {
+			if _, err := fmt.Fprintf(w, "    fix: %s\n", r.Fix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes results as a JSON array.
+func WriteJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+func checkConfig(env *Env) Result {
+	r := Result{Check: "config", Status: OK, Detail: env.ConfigPath}
+	if env.ConfigErr != nil {
+		r.Status = Error
+		r.Detail = env.ConfigErr.Error()
+		r.Fix = "fix or remove " + env.ConfigPath + ", the defaults are used meanwhile"
+	}
+	return r
+}
+
+// usedStore is a store the plugin reads, and its backend.
+type usedStore struct {
+	backend string
+	store   *config.Store
+}
+
+// usedStores returns the stores of the enabled sources, or else the store of
+// the configured backend, picking it like the plugin for "auto".
+func usedStores(env *Env) []usedStore {
+	cfg := env.Config
+	if len(cfg.Sources) == 0 {
+		backend := cfg.StoreBackend(func() bool {
+			_, err := env.LookPath(env.Gopass)
+			return err == nil
+		}, func() bool {
+			_, err := env.Stat(passDir(&cfg.Store))
+			return err == nil
+		})
+		return []usedStore{{backend, &cfg.Store}}
+	}
+	var stores []usedStore
+	for i, src := range cfg.Sources {
+		if !src.Disabled {
+			stores = append(stores, usedStore{src.Backend, &cfg.Sources[i].Store})
+		}
+	}
+	return stores
+}
+
+// usedBy returns the settings of the stores of backend.
+func usedBy(stores []usedStore, backend string) []*config.Store {
+	var settings []*config.Store
+	for _, s := range stores {
+		if s.backend == backend {
+			settings = append(settings, s.store)
+		}
+	}
+	return settings
+}
+
+// passDir returns the directory of the store of pass configured by s.
+func passDir(s *config.Store) string {
+	if s.PasswordStoreDir != "" {
+		return s.PasswordStoreDir
+	}
+	return store.DefaultPassDir()
+}
+
+// withoutGopass returns why gopass isn't used by the stores of cfg, or ""
+// when it is.
+func withoutGopass(cfg *config.Config, stores []usedStore) string {
+	switch {
+	case len(usedBy(stores, "gopass")) > 0:
+		return ""
+	case len(cfg.Sources) > 0:
+		return "no source uses gopass"
+	case cfg.Backend == "auto":
+		return "gopass not found, using the pass store"
+	}
+	return "using the " + cfg.Backend + " backend"
+}
+
+func checkGopass(env *Env) Result {
+	path, err := env.LookPath(env.Gopass)
+	if err != nil {
+		fix := "install gopass from https://github.com/gopasspw/gopass, or set \"gopass\" to its path in " + env.ConfigPath
+		if env.Config.Gopass != "" {
+			fix = "fix the \"gopass\" path in " + env.ConfigPath
+		}
+		return Result{Check: "gopass", Status: Error, Detail: err.Error(), Fix: fix}
+	}
+	return Result{Check: "gopass", Status: OK, Detail: path}
+}
+
+func checkGopassVersion(env *Env) Result {
+	out, err := env.Command(env.Gopass, "version")
+	if err != nil {
+		return Result{Check: "gopass version", Status: Error, Detail: commandError(err, out), Fix: "reinstall gopass"}
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return Result{Check: "gopass version", Status: OK, Detail: version}
+}
+
+func checkGopassList(env *Env) Result {
+	args := []string{"ls", "-flat"}
+	if !env.Config.Sync {
+		args = append([]string{"--nosync"}, args...)
+	}
+	out, err := env.Command(env.Gopass, args...)
+	if err != nil {
+		return Result{
+			Check:  "gopass ls",
+			Status: Error,
+			Detail: commandError(err, out),
+			Fix:    "run `gopass ls` in a terminal: gopass may need to be set up with `gopass setup`, or be waiting for some input",
+		}
+	}
+	n := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) != "" {
+			n++
+		}
+	}
+	if n == 0 {
+		return Result{Check: "gopass ls", Status: Warning, Detail: "the store is empty", Fix: "add a secret with `gopass insert`"}
+	}
+	return Result{Check: "gopass ls", Status: OK, Detail: fmt.Sprintf("%d entries", n)}
+}
+
+// checkGPG checks that gpg is installed to decrypt the secrets of pass,
+// preferring gpg2 like pass does.
+func checkGPG(env *Env) Result {
+	path, err := env.LookPath("gpg2")
+	if err != nil {
+		path, err = env.LookPath("gpg")
+	}
+	if err != nil {
+		return Result{Check: "gpg", Status: Error, Detail: err.Error(), Fix: "install GnuPG, which decrypts the secrets of pass"}
+	}
+	return Result{Check: "gpg", Status: OK, Detail: path}
+}
+
+// checkPassStore checks that the directory of the store of pass exists.
+func checkPassStore(env *Env, s *config.Store) Result {
+	dir := passDir(s)
+	_, err := env.Stat(dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return Result{
+			Check:  "pass store",
+			Status: Error,
+			Detail: dir + " does not exist",
+			Fix:    "create the store with `pass init`, or set \"password_store_dir\" to its directory in " + env.ConfigPath,
+		}
+	case err != nil:
+		return Result{Check: "pass store", Status: Error, Detail: err.Error(), Fix: "check the permissions of " + dir}
+	}
+	return Result{Check: "pass store", Status: OK, Detail: dir}
+}
+
+// checkKeePassXCCLI checks that keepassxc-cli is installed to read the
+// KeePassXC databases.
+func checkKeePassXCCLI(env *Env) Result {
+	path, err := env.LookPath("keepassxc-cli")
+	if err != nil {
+		return Result{Check: "keepassxc-cli", Status: Error, Detail: err.Error(), Fix: "install KeePassXC, which comes with keepassxc-cli"}
+	}
+	return Result{Check: "keepassxc-cli", Status: OK, Detail: path}
+}
+
+// checkKeePassXCFile checks that the file at path, configured by setting,
+// exists.
+func checkKeePassXCFile(env *Env, check, setting, path string) Result {
+	_, err := env.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return Result{
+			Check:  check,
+			Status: Error,
+			Detail: path + " does not exist",
+			Fix:    "set \"" + setting + "\" to its path in " + env.ConfigPath,
+		}
+	case err != nil:
+		return Result{Check: check, Status: Error, Detail: err.Error(), Fix: "check the permissions of " + path}
+	}
+	return Result{Check: check, Status: OK, Detail: path}
+}
+
+// checkKeePassXCPassword checks that the command printing the password of a
+// KeePassXC database runs, without showing the command nor what it printed
+// since either may hold the password.
+func checkKeePassXCPassword(env *Env, command string) Result {
+	r := Result{Check: "keepassxc password command", Status: OK, Detail: "a password was printed"}
+	out, err := env.Command("sh", "-c", command)
+	switch {
+	case err != nil:
+		r.Status = Error
+		r.Detail = err.Error()
+		r.Fix = "run \"keepassxc_password_command\" of " + env.ConfigPath + " in a terminal to see what fails"
+	case len(bytes.TrimSpace(out)) == 0:
+		r.Status = Warning
+		r.Detail = "nothing was printed"
+		r.Fix = "check that \"keepassxc_password_command\" of " + env.ConfigPath + " prints the password of the database"
+	}
+	return r
+}
+
+// checkCrypto checks that secrets can be decrypted without a terminal, with
+// either a running gpg-agent or an age identity.
+func checkCrypto(env *Env) Result {
+	r := Result{Check: "decryption", Status: OK}
+	out, gpgErr := env.Command("gpg-connect-agent", "/bye")
+	if gpgErr == nil {
+		r.Detail = "gpg-agent is available"
+		return r
+	}
+	configHome := env.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(env.Getenv("HOME"), ".config")
+	}
+	identities := filepath.Join(configHome, "gopass", "age", "identities")
+	if _, err := env.Stat(identities); err == nil {
+		r.Detail = "age identities in " + identities
+		return r
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return Result{Check: "decryption", Status: Error, Detail: err.Error(), Fix: "check the permissions of " + identities}
+	}
+	r.Status = Warning
+	r.Detail = "no gpg-agent (" + commandError(gpgErr, out) + ") and no age identity found"
+	r.Fix = "start gpg-agent with `gpgconf --launch gpg-agent`, or add an age identity with `gopass age identities add`"
+	return r
+}
+
+func checkUinput(env *Env) Result {
+	err := env.Typer("uinput")
+	if err == nil {
+		return Result{Check: "uinput", Status: OK, Detail: "/dev/uinput is writable"}
+	}
+	r := Result{
+		Check:  "uinput",
+		Status: Warning,
+		Detail: err.Error(),
+		Fix: "allow your user to write to /dev/uinput, e.g. with a udev rule such as " +
+			`KERNEL=="uinput", GROUP="input", MODE="0660", OPTIONS+="static_node=uinput"` +
+			" and adding yourself to the input group",
+	}
+	if env.Config.Typer == "uinput" {
+		r.Status = Error
+	}
+	return r
+}
+
+// checkTyper checks that the configured typer, or any when it is automatically
+// detected, can send key presses.
+func checkTyper(env *Env) Result {
+	r := Result{Check: "typer"}
+	backends := autotype.Backends
+	if env.Config.Typer != "auto" {
+		backends = []string{env.Config.Typer}
+	}
+	var errs []string
+	for _, name := range backends {
+		if err := env.Typer(name); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		r.Status = OK
+		r.Detail = "using " + name
+		return r
+	}
+	r.Status = Error
+	r.Detail = strings.Join(errs, "; ")
+	if env.Config.Typer != "auto" {
+		r.Fix = fmt.Sprintf("install %s, or set \"typer\" to \"auto\" in %s", env.Config.Typer, env.ConfigPath)
+	} else {
+		r.Fix = "give access to /dev/uinput, or install wtype, or install ydotool and start ydotoold"
+	}
+	return r
+}
+
+func checkClipboard(env *Env) Result {
+	err := env.Clipboard()
+	if err == nil {
+		return Result{Check: "clipboard", Status: OK, Detail: "wl-copy and wl-paste found"}
+	}
+	r := Result{Check: "clipboard", Status: Error, Detail: err.Error(), Fix: "install wl-clipboard, which only works in a Wayland session"}
+	if autotype.Mode(env.Config.PasteMode) == autotype.ModeType && env.Config.DefaultAction != "copy" {
+		// only copying keys and OTP codes needs the clipboard
+		r.Status = Warning
+	}
+	return r
+}
+
+// checkPlugin looks for the installed plugin, returning its directory.
+func checkPlugin(env *Env) (Result, string) {
+	for _, dir := range env.PluginDirs {
+		if _, err := env.Stat(filepath.Join(dir, install.RONName)); err != nil {
+			continue
+		}
+		if _, err := env.Stat(filepath.Join(dir, install.BinaryName)); err != nil {
+			return Result{
+				Check:  "plugin",
+				Status: Error,
+				Detail: fmt.Sprintf("%s found without the plugin binary: %v", install.RONName, err),
+				Fix:    "run `cosmic-gopass-plugin install`",
+			}, dir
+		}
+		return Result{Check: "plugin", Status: OK, Detail: "installed in " + dir}, dir
+	}
+	return Result{
+		Check:  "plugin",
+		Status: Error,
+		Detail: "not installed in " + strings.Join(env.PluginDirs, ", "),
+		Fix:    "run `cosmic-gopass-plugin install`",
+	}, ""
+}
+
+// checkPrefix checks that the launcher sends the plugin the queries starting
+// with the configured prefixes.
+func checkPrefix(env *Env, dir string) Result {
+	r := Result{Check: "prefix", Fix: "run `cosmic-gopass-plugin install` again to update " + install.RONName}
+	regex, err := install.InstalledRegex(dir)
+	if err != nil {
+		r.Status = Error
+		r.Detail = err.Error()
+		return r
+	}
+	if want := install.Regex(env.Config); regex != want {
+		r.Status = Error
+		r.Detail = fmt.Sprintf("%s matches %q but the configured prefixes are %q and %q", install.RONName, regex, env.Config.Prefix, env.Config.OTPPrefix)
+		return r
+	}
+	return Result{Check: "prefix", Status: OK, Detail: fmt.Sprintf("%q and %q", env.Config.Prefix, env.Config.OTPPrefix)}
+}
+
+// commandError describes a failed command with the end of its output.
+func commandError(err error, out []byte) string {
+	msg := strings.TrimSpace(string(out))
+	if msg == "" {
+		return err.Error()
+	}
+	if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+		msg = msg[i+1:]
+	}
+	return fmt.Sprintf("%v: %s", err, msg)
+}
@@ -0,0 +1,385 @@
+package doctor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/config"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/install"
+)
+
+// healthyEnv returns an environment where every check passes, with the
+// plugin installed in a temporary directory.
+func healthyEnv(t *testing.T) *Env {
+	t.Helper()
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "gopass")
+	bin := filepath.Join(tmp, "build")
+	if err := os.WriteFile(bin, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	if err := install.Install(dir, bin, cfg); err != nil {
+		t.Fatal(err)
+	}
+	return &Env{
+		Config:     cfg,
+		ConfigPath: "/config.json",
+		Gopass:     "gopass",
+		PluginDirs: []string{filepath.Join(tmp, "missing"), dir},
+		Command: func(name string, args ...string) ([]byte, error) {
+			switch strings.Join(append([]string{name}, args...), " ") {
+			case "gopass version":
+				return []byte("gopass 1.15.11 go1.22.0 linux amd64\n"), nil
+			case "gopass --nosync ls -flat":
+				return []byte("a/b\nc\n"), nil
+			case "gpg-connect-agent /bye":
+				return nil, nil
+			}
+			return nil, errors.New("unexpected command " + name)
+		},
+		LookPath:  func(file string) (string, error) { return "/usr/bin/" + file, nil },
+		Getenv:    func(string) string { return "" },
+		Stat:      os.Stat,
+		Typer:     func(string) error { return nil },
+		Clipboard: func() error { return nil },
+	}
+}
+
+// statuses returns the status of each check of results.
+func statuses(results []Result) map[string]Status {
+	m := make(map[string]Status, len(results))
+	for _, r := range results {
+		m[r.Check] = r.Status
+	}
+	return m
+}
+
+func TestHealthy(t *testing.T) {
+	results := Run(healthyEnv(t))
+	for _, r := range results {
+		if r.Status != OK {
+			t.Errorf("%s: %s (%s)", r.Check, r.Status, r.Detail)
+		}
+	}
+	if Failed(results) {
+		t.Error("Failed should be false")
+	}
+	if len(results) != 10 {
+		t.Errorf("got %d results, want 10", len(results))
+	}
+}
+
+func TestGopassMissing(t *testing.T) {
+	env := healthyEnv(t)
+	env.LookPath = func(file string) (string, error) { return "", errors.New("not found") }
+	results := Run(env)
+	got := statuses(results)
+	if got["gopass"] != Error || got["gopass version"] != Skipped || got["gopass ls"] != Skipped {
+		t.Errorf("unexpected statuses %v", got)
+	}
+	if !Failed(results) {
+		t.Error("Failed should be true")
+	}
+	if !strings.Contains(results[1].Fix, "install gopass") {
+		t.Errorf("unexpected fix %q", results[1].Fix)
+	}
+}
+
+func TestPassBackend(t *testing.T) {
+	env := healthyEnv(t)
+	env.Config.Backend = "pass"
+	env.Config.PasswordStoreDir = t.TempDir()
+	gopassMissing := func(file string) (string, error) {
+		if file == "gopass" {
+			return "", errors.New("not found")
+		}
+		return "/usr/bin/" + file, nil
+	}
+	env.LookPath = gopassMissing
+	results := Run(env)
+	got := statuses(results)
+	if got["gopass"] != Skipped || got["gopass version"] != Skipped || got["gopass ls"] != Skipped {
+		t.Errorf("unexpected statuses %v", got)
+	}
+	if got["gpg"] != OK || got["pass store"] != OK {
+		t.Errorf("the pass store should be checked, got %v", got)
+	}
+	if Failed(results) {
+		t.Error("a missing gopass should not fail with the pass backend")
+	}
+
+	env.Config.PasswordStoreDir = filepath.Join(env.Config.PasswordStoreDir, "missing")
+	for _, r := range Run(env) {
+		if r.Check == "pass store" && (r.Status != Error || r.Detail != env.Config.PasswordStoreDir+" does not exist") {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+	env.LookPath = func(file string) (string, error) { return "", errors.New("not found") }
+	if got := statuses(Run(env)); got["gpg"] != Error {
+		t.Errorf("gpg = %s when missing, want an error", got["gpg"])
+	}
+
+	env = healthyEnv(t)
+	env.LookPath = gopassMissing
+	env.Config.Sources = []config.Source{
+		{Name: "work", Backend: "gopass", Disabled: true},
+		{Name: "personal", Backend: "pass", Store: config.Store{PasswordStoreDir: t.TempDir()}},
+	}
+	if r := Run(env); statuses(r)["gopass"] != Skipped || r[1].Detail != "no source uses gopass" {
+		t.Errorf("unexpected results %+v", r)
+	}
+	env.Config.Sources[0].Disabled = false
+	if got := statuses(Run(env)); got["gopass"] != Error {
+		t.Errorf("gopass should be checked when a source uses it, got %v", got)
+	}
+}
+
+func TestKeePassXCBackend(t *testing.T) {
+	env := healthyEnv(t)
+	tmp := t.TempDir()
+	database, keyFile := filepath.Join(tmp, "db.kdbx"), filepath.Join(tmp, "db.keyx")
+	for _, name := range []string{database, keyFile} {
+		if err := os.WriteFile(name, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env.Config.Backend = "keepassxc"
+	env.Config.Store = config.Store{KeePassXCDatabase: database, KeePassXCKeyFile: keyFile, KeePassXCPasswordCommand: "secret-tool lookup kp db"}
+	command := env.Command
+	var password []byte
+	var passwordErr error
+	env.Command = func(name string, args ...string) ([]byte, error) {
+		if name == "sh" && slices.Equal(args, []string{"-c", "secret-tool lookup kp db"}) {
+			return password, passwordErr
+		}
+		return command(name, args...)
+	}
+
+	password = []byte("hunter2\n")
+	results := Run(env)
+	got := statuses(results)
+	if got["gopass"] != Skipped || got["keepassxc-cli"] != OK || got["keepassxc database"] != OK || got["keepassxc key file"] != OK || got["keepassxc password command"] != OK || Failed(results) {
+		t.Errorf("unexpected statuses %v", got)
+	}
+	for _, r := range results {
+		if strings.Contains(r.Detail, "hunter2") || strings.Contains(r.Detail, "secret-tool") {
+			t.Errorf("%s shows the password command or its output: %q", r.Check, r.Detail)
+		}
+	}
+
+	password = nil
+	if got := statuses(Run(env)); got["keepassxc password command"] != Warning {
+		t.Errorf("keepassxc password command = %s when printing nothing, want a warning", got["keepassxc password command"])
+	}
+	passwordErr = errors.New("exit status 1")
+	if got := statuses(Run(env)); got["keepassxc password command"] != Error {
+		t.Errorf("keepassxc password command = %s when failing, want an error", got["keepassxc password command"])
+	}
+
+	env.Config.KeePassXCDatabase = filepath.Join(tmp, "missing.kdbx")
+	env.Config.KeePassXCPasswordCommand = ""
+	env.LookPath = func(file string) (string, error) { return "", errors.New("not found") }
+	results = Run(env)
+	got = statuses(results)
+	if got["keepassxc-cli"] != Error || got["keepassxc database"] != Error || got["keepassxc password command"] != "" {
+		t.Errorf("unexpected statuses %v", got)
+	}
+	for _, r := range results {
+		if r.Check == "keepassxc database" && r.Detail != env.Config.KeePassXCDatabase+" does not exist" {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+
+	// and in sources
+	env = healthyEnv(t)
+	env.Config.Sources = []config.Source{
+		{Name: "personal", Backend: "gopass"},
+		{Name: "work", Backend: "keepassxc", Store: config.Store{KeePassXCDatabase: database, KeePassXCKeyFile: filepath.Join(tmp, "missing.keyx")}},
+	}
+	results = Run(env)
+	got = statuses(results)
+	if got["gopass ls"] != OK || got["keepassxc-cli"] != OK || got["keepassxc database"] != OK || got["keepassxc key file"] != Error {
+		t.Errorf("unexpected statuses %v", got)
+	}
+}
+
+func TestAutoBackend(t *testing.T) {
+	env := healthyEnv(t)
+	env.Config.PasswordStoreDir = t.TempDir()
+	if got := statuses(Run(env)); got["gopass ls"] != OK || got["pass store"] != "" {
+		t.Errorf("gopass should be used when installed, got %v", got)
+	}
+
+	env.LookPath = func(file string) (string, error) {
+		if file == "gopass" {
+			return "", errors.New("not found")
+		}
+		return "/usr/bin/" + file, nil
+	}
+	results := Run(env)
+	if got := statuses(results); got["gopass"] != Skipped || got["pass store"] != OK || Failed(results) {
+		t.Errorf("pass should be used without gopass, got %v", got)
+	}
+
+	// without a pass store either, gopass is missing
+	env.Config.PasswordStoreDir = filepath.Join(env.Config.PasswordStoreDir, "missing")
+	if got := statuses(Run(env)); got["gopass"] != Error || got["pass store"] != "" {
+		t.Errorf("gopass should be reported missing, got %v", got)
+	}
+}
+
+func TestGopassListFails(t *testing.T) {
+	env := healthyEnv(t)
+	command := env.Command
+	env.Command = func(name string, args ...string) ([]byte, error) {
+		if len(args) > 1 && args[1] == "ls" {
+			return []byte("Error: failed to decrypt\n"), errors.New("exit status 1")
+		}
+		return command(name, args...)
+	}
+	for _, r := range Run(env) {
+		if r.Check != "gopass ls" {
+			continue
+		}
+		if r.Status != Error || r.Detail != "exit status 1: Error: failed to decrypt" || r.Fix == "" {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+}
+
+func TestSystemCommand(t *testing.T) {
+	env := System(config.Default(), nil, "gopass")
+	// warnings on stderr aren't counted as entries
+	if out, err := env.Command("sh", "-c", "echo a/b; echo 'WARNING: old config' >&2"); err != nil || string(out) != "a/b\n" {
+		t.Errorf("Command = %q, %v, want the standard output", out, err)
+	}
+	if out, err := env.Command("sh", "-c", "echo 'Error: failed to decrypt' >&2; exit 1"); err == nil || string(out) != "Error: failed to decrypt\n" {
+		t.Errorf("failed Command = %q, %v, want the error output", out, err)
+	}
+}
+
+func TestTyperFallback(t *testing.T) {
+	env := healthyEnv(t)
+	env.Typer = func(name string) error {
+		if name == "wtype" {
+			return nil
+		}
+		return errors.New(name + " unavailable")
+	}
+	got := statuses(Run(env))
+	if got["uinput"] != Warning || got["typer"] != OK {
+		t.Errorf("unexpected statuses %v", got)
+	}
+
+	env.Config.Typer = "uinput"
+	got = statuses(Run(env))
+	if got["uinput"] != Error || got["typer"] != Error {
+		t.Errorf("unexpected statuses with uinput configured %v", got)
+	}
+}
+
+func TestClipboardOnlyNeededToCopy(t *testing.T) {
+	env := healthyEnv(t)
+	env.Clipboard = func() error { return errors.New("not in a Wayland session") }
+	if got := statuses(Run(env)); got["clipboard"] != Error {
+		t.Errorf("clipboard = %s, want an error", got["clipboard"])
+	}
+	env.Config.PasteMode = "type"
+	if got := statuses(Run(env)); got["clipboard"] != Warning {
+		t.Errorf("clipboard = %s in type mode, want a warning", got["clipboard"])
+	}
+}
+
+func TestPluginNotInstalled(t *testing.T) {
+	env := healthyEnv(t)
+	env.PluginDirs = env.PluginDirs[:1]
+	got := statuses(Run(env))
+	if got["plugin"] != Error || got["prefix"] != Skipped {
+		t.Errorf("unexpected statuses %v", got)
+	}
+}
+
+func TestPrefixMismatch(t *testing.T) {
+	env := healthyEnv(t)
+	env.Config.Prefix = "pw "
+	for _, r := range Run(env) {
+		if r.Check == "prefix" && (r.Status != Error || !strings.Contains(r.Detail, `"pw "`) || !strings.Contains(r.Fix, "install")) {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+}
+
+func TestConfigError(t *testing.T) {
+	env := healthyEnv(t)
+	env.ConfigErr = errors.New("/config.json: line 1, column 2: oops")
+	if got := statuses(Run(env)); got["config"] != Error {
+		t.Errorf("config = %s, want an error", got["config"])
+	}
+}
+
+func TestAgeIdentities(t *testing.T) {
+	env := healthyEnv(t)
+	env.Command = func(name string, args ...string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}
+	env.Getenv = func(key string) string {
+		if key == "XDG_CONFIG_HOME" {
+			return "/config"
+		}
+		return ""
+	}
+	files := fstest.MapFS{}
+	env.Stat = func(name string) (fs.FileInfo, error) {
+		if rest, ok := strings.CutPrefix(name, "/config/"); ok {
+			return fs.Stat(files, rest)
+		}
+		return os.Stat(name)
+	}
+	if got := statuses(Run(env)); got["decryption"] != Warning {
+		t.Errorf("decryption = %s without gpg-agent nor age, want a warning", got["decryption"])
+	}
+
+	files["gopass/age/identities"] = &fstest.MapFile{}
+	if got := statuses(Run(env)); got["decryption"] != OK {
+		t.Errorf("decryption = %s with age identities, want ok", got["decryption"])
+	}
+}
+
+func TestWrite(t *testing.T) {
+	results := []Result{
+		{Check: "gopass", Status: OK, Detail: "/usr/bin/gopass"},
+		{Check: "plugin", Status: Error, Detail: "not installed", Fix: "run `cosmic-gopass-plugin install`"},
+	}
+	var text bytes.Buffer
+	if err := WriteText(&text, results); err != nil {
+		t.Fatal(err)
+	}
+	want := "[ok] gopass: /usr/bin/gopass\n[error] plugin: not installed\n    fix: run `cosmic-gopass-plugin install`\n"
+	if text.String() != want {
+		t.Errorf("WriteText = %q, want %q", text.String(), want)
+	}
+
+	var js bytes.Buffer
+	if err := WriteJSON(&js, results); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Result
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[1] != results[1] {
+		t.Errorf("WriteJSON round trip = %+v", decoded)
+	}
+	if strings.Contains(js.String(), `"fix": ""`) {
+		t.Errorf("empty fixes should be omitted: %s", js.String())
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/index"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/launcher"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/store"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/watch"
+)
+
+// loadEntries lists the entries of s.
+func loadEntries(s store.Store) ([]string, error) {
+	log.Println("Loading entries...")
+	entries, err := s.List()
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		// a federated store still lists the sources that didn't fail
+		if len(entries) == 0 {
+			return nil, err
+		}
+	}
+	log.Printf("Loaded %d entries", len(entries))
+	return entries, nil
+}
+
+// errLoading is returned while the entries are loaded for the first time, or
+// again after listing them failed.
+var errLoading = errors.New("loading the entries")
+
+// loadingResult is displayed instead of the results while there are no
+// entries to search yet.
+var loadingResult = launcher.SearchResult{
+	Name:        "Loading entries…",
+	Description: "Listing the password store, type again in a moment",
+	IconName:    "process-working",
+	Disabled:    true,
+}
+
+// entryLoader loads the entries in the background, while the entries cached
+// by the previous run are searched, so that starting the plugin doesn't
+// delay the first searches.
+type entryLoader struct {
+	index *index.Index
+	// cache is the path of the entry cache, empty to disable it.
+	cache string
+	// list lists the entries of the stores.
+	list func() ([]string, error)
+
+	mu sync.Mutex
+	// ready is set once the index holds entries, from the cache or listed.
+	ready   bool
+	loading bool
+	// err is the error of the last listing of the entries.
+	err error
+}
+
+// newEntryLoader returns a loader of the entries listed by list, starting
+// with the entries cached at cache if any.
+func newEntryLoader(cache string, list func() ([]string, error)) *entryLoader {
+	l := &entryLoader{index: index.New(nil), cache: cache, list: list}
+	if cache == "" {
+		return l
+	}
+	cached, err := index.LoadCache(cache)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+	}
+	if len(cached) > 0 {
+		l.index.Set(cached)
+		l.ready = true
+		log.Printf("Loaded %d entries from the cache", len(cached))
+	}
+	return l
+}
+
+// start loads the entries in the background, and then calls then.
+func (l *entryLoader) start(then func()) {
+	l.mu.Lock()
+	l.loading = true
+	l.mu.Unlock()
+	go func() {
+		l.load()
+		then()
+	}()
+}
+
+// load lists the entries, replaces the indexed ones with them and saves
+// them to the cache.
+func (l *entryLoader) load() error {
+	l.mu.Lock()
+	l.loading = true
+	l.mu.Unlock()
+
+	entries, err := l.list()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loading, l.err = false, err
+	if err != nil {
+		return err
+	}
+	l.index.Set(entries)
+	l.ready = true
+	l.save(entries)
+	return nil
+}
+
+// update applies the changes of the stores to the indexed entries, and
+// saves them to the cache.
+func (l *entryLoader) update(added, removed []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.index.Update(added, removed)
+	l.save(slices.Sorted(maps.Values(l.index.Entries())))
+}
+
+// save saves entries to the cache, if enabled.
+func (l *entryLoader) save(entries []string) {
+	if l.cache == "" {
+		return
+	}
+	if err := index.SaveCache(l.cache, entries); err != nil {
+		log.Printf("ERROR: %v", err)
+	}
+}
+
+// entries returns the entries to search, or errLoading while they are
+// loaded.
+func (l *entryLoader) entries() (map[string]string, error) {
+	l.mu.Lock()
+	ready, loading := l.ready, l.loading
+	l.mu.Unlock()
+	switch {
+	case ready:
+		// the cached entries are still better than nothing if listing failed
+		return l.index.Entries(), nil
+	case !loading:
+		// the store may have been unlocked or gopass installed since the
+		// last failure, which is listed again without blocking the launcher
+		l.start(func() {})
+	}
+	return nil, errLoading
+}
+
+// failure returns the error of the last listing of the entries, nil if it
+// succeeded or is the first one.
+func (l *entryLoader) failure() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// watchDelay is how long the changes of the stores are gathered before being
+// applied, since gopass and git usually change several files at once.
+const watchDelay = 200 * time.Millisecond
+
+// watchStores keeps the entries of s loaded by l up to date with the changes
+// of its directories.
+func watchStores(s store.Store, l *entryLoader) {
+	watchable, ok := s.(store.Watchable)
+	if !ok {
+		return
+	}
+	stores, err := watchable.Dirs()
+	if err != nil {
+		log.Printf("ERROR: %v, entries changed from now on will show after restarting the launcher", err)
+		// a federated store still watches the sources that didn't fail
+		if len(stores) == 0 {
+			return
+		}
+	}
+	w, err := watch.New(stores)
+	if err != nil {
+		log.Printf("ERROR: %v, entries changed from now on will show after restarting the launcher", err)
+		return
+	}
+	err = w.Run(context.Background(), watchDelay, func(c watch.Change) {
+		if c.Reload {
+			l.load()
+			return
+		}
+		l.update(c.Added, c.Removed)
+		log.Printf("Stores changed: %d entries added, %d removed", len(c.Added), len(c.Removed))
+	})
+	log.Printf("ERROR: stopped watching the stores: %v", err)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/index"
+)
+
+// fakeList lists entries once released, returning the next error of errs if any.
+type fakeList struct {
+	release chan struct{}
+	entries []string
+	errs    []error
+}
+
+func (f *fakeList) list() ([]string, error) {
+	<-f.release
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f.entries, nil
+}
+
+func names(entries map[string]string) []string {
+	return slices.Sorted(maps.Values(entries))
+}
+
+func TestEntryLoaderServesCacheWhileLoading(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "entries.json")
+	if err := index.SaveCache(cache, []string{"cached"}); err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeList{release: make(chan struct{}), entries: []string{"listed", "new"}}
+	l := newEntryLoader(cache, f.list)
+	done := make(chan struct{})
+	l.start(func() { close(done) })
+
+	if got, err := l.entries(); err != nil || !slices.Equal(names(got), []string{"cached"}) {
+		t.Errorf("entries while loading = %q, %v, want the cached ones", names(got), err)
+	}
+	close(f.release)
+	<-done
+	if got, err := l.entries(); err != nil || !slices.Equal(names(got), []string{"listed", "new"}) {
+		t.Errorf("entries once loaded = %q, %v", names(got), err)
+	}
+	if cached, err := index.LoadCache(cache); err != nil || !slices.Equal(cached, []string{"listed", "new"}) {
+		t.Errorf("cache = %q, %v, want the listed entries", cached, err)
+	}
+}
+
+func TestEntryLoaderWithoutCache(t *testing.T) {
+	listErr := errors.New("gopass ls failed")
+	f := &fakeList{release: make(chan struct{}), entries: []string{"a"}, errs: []error{listErr}}
+	l := newEntryLoader(filepath.Join(t.TempDir(), "entries.json"), f.list)
+	done := make(chan struct{})
+	l.start(func() { close(done) })
+
+	if _, err := l.entries(); !errors.Is(err, errLoading) {
+		t.Errorf("entries while loading without cache = %v, want %v", err, errLoading)
+	}
+	f.release <- struct{}{}
+	<-done
+	if err := l.failure(); !errors.Is(err, listErr) {
+		t.Errorf("failure = %v, want %v", err, listErr)
+	}
+	// failures are retried in the background by the next searches
+	if _, err := l.entries(); !errors.Is(err, errLoading) {
+		t.Errorf("entries after a failure = %v, want %v", err, errLoading)
+	}
+	close(f.release)
+	got, err := l.entries()
+	for deadline := time.Now().Add(5 * time.Second); errors.Is(err, errLoading) && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		got, err = l.entries()
+	}
+	if err != nil || !slices.Equal(names(got), []string{"a"}) {
+		t.Errorf("entries after a retry = %q, %v", names(got), err)
+	}
+}
+
+func TestEntryLoaderUpdate(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "entries.json")
+	f := &fakeList{release: make(chan struct{}), entries: []string{"a", "dir/b", "dir/c"}}
+	close(f.release)
+	l := newEntryLoader(cache, f.list)
+	if err := l.load(); err != nil {
+		t.Fatal(err)
+	}
+	l.update([]string{"d"}, []string{"dir/"})
+	want := []string{"a", "d"}
+	if got, err := l.entries(); err != nil || !slices.Equal(names(got), want) {
+		t.Errorf("entries after an update = %q, %v, want %q", names(got), err, want)
+	}
+	if cached, err := index.LoadCache(cache); err != nil || !slices.Equal(cached, want) {
+		t.Errorf("cache after an update = %q, %v, want %q", cached, err, want)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/launcher"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/store"
+)
+
+// errorIcon is the icon of the results reporting errors.
+const errorIcon = "dialog-error"
+
+// errorResult returns the result showing err in the launcher, with a hint
+// on how to fix it when we can tell what went wrong.
+func errorResult(err error) launcher.SearchResult {
+	r := launcher.SearchResult{Name: "Error: " + err.Error(), IconName: errorIcon}
+	var exitErr *exec.ExitError
+	var pathErr *fs.PathError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		r.Name = "Entry not found"
+		r.Description = err.Error() + ", it may have been removed since the plugin started"
+	case missingCommand(err) != "":
+		// such as gpg, for the pass backend
+		name := missingCommand(err)
+		r.Name = name + " was not found"
+		r.Description = "Install gopass or set its path in the configuration, `cosmic-gopass-plugin doctor` can help"
+		if name != "gopass" {
+			r.Description = "Install " + name + ", `cosmic-gopass-plugin doctor` can help"
+		}
+	case errors.As(err, &pathErr) && errors.Is(err, fs.ErrNotExist):
+		r.Name = pathErr.Path + " does not exist"
+		r.Description = "Check the paths of the configuration, `cosmic-gopass-plugin doctor` can help"
+	case errors.Is(err, fs.ErrPermission):
+		r.Name = "Permission denied"
+		r.Description = err.Error()
+	case errors.As(err, &exitErr):
+		stderr := lastLine(string(exitErr.Stderr))
+		lower := strings.ToLower(stderr)
+		switch {
+		case strings.Contains(lower, "invalid credentials"):
+			r.Name = "Failed to unlock the database"
+			r.Description = "Check the KeePassXC key file and password command of the configuration. " + stderr
+		case strings.Contains(lower, "decrypt") || strings.Contains(lower, "gpg") || strings.Contains(lower, "identity"):
+			r.Name = "Failed to decrypt the secret"
+			r.Description = "Is your gpg-agent or age identity unlocked? " + stderr
+		case strings.Contains(lower, "not found") || strings.Contains(lower, "not in the password store"):
+			r.Name = "Entry not found"
+			r.Description = stderr + ", it may have been removed since the plugin started"
+		default:
+			r.Name = "A command failed"
+			var cmdErr *store.CommandError
+			if errors.As(err, &cmdErr) {
+				r.Name = cmdErr.Command + " failed"
+			}
+			r.Description = stderr
+		}
+		if stderr == "" {
+			r.Description = err.Error() + ", `cosmic-gopass-plugin doctor` can help"
+		}
+	default:
+		r.Description = "See the logs with `journalctl -t gopass-plugin` for details"
+	}
+	return r
+}
+
+// missingCommand returns the name of the command err failed to run because
+// it wasn't found, either in the PATH or at the configured path, or "".
+func missingCommand(err error) string {
+	var execErr *exec.Error
+	if errors.As(err, &execErr) && errors.Is(err, exec.ErrNotFound) {
+		return filepath.Base(execErr.Name)
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) && pathErr.Op == "fork/exec" && errors.Is(err, fs.ErrNotExist) {
+		return filepath.Base(pathErr.Path)
+	}
+	return ""
+}
+
+// lastLine returns the last non-empty line of s, which is where gopass
+// writes what went wrong.
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(s)
+}
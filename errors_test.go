@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/store"
+)
+
+// exitError returns the error of a command failing with stderr.
+func exitError(t *testing.T, stderr string) error {
+	t.Helper()
+	_, err := exec.Command("sh", "-c", "printf '%s' \"$0\" >&2; exit 1", stderr).Output()
+	if err == nil {
+		t.Fatal("command should fail")
+	}
+	return &store.CommandError{Command: "gopass show -o", Err: err}
+}
+
+func TestErrorResult(t *testing.T) {
+	for _, tc := range []struct {
+		err         error
+		name        string
+		description string
+	}{
+		{fmt.Errorf("gopass ls failed: %w", &exec.Error{Name: "gopass", Err: exec.ErrNotFound}), "gopass was not found", "doctor"},
+		{fmt.Errorf("gpg --decrypt failed: %w", &exec.Error{Name: "gpg2", Err: exec.ErrNotFound}), "gpg2 was not found", "Install gpg2"},
+		{fmt.Errorf("gopass ls failed: %w", exec.Command("/opt/gopass/bin/gopass").Run()), "gopass was not found", "set its path"},
+		{fmt.Errorf("listing the password store: %w", &fs.PathError{Op: "lstat", Path: "/srv/pass", Err: fs.ErrNotExist}), "/srv/pass does not exist", "paths of the configuration"},
+		{fmt.Errorf("entry a/b is %w", store.ErrNotFound), "Entry not found", "entry a/b is not in the password store, it may"},
+		{exitError(t, "\ngpg: decryption failed: No secret key\n"), "Failed to decrypt the secret", "gpg: decryption failed: No secret key"},
+		{exitError(t, "Error: entry is not in the password store\n"), "Entry not found", "removed"},
+		{exitError(t, "Error while reading the database: Invalid credentials were provided, please try again.\n"), "Failed to unlock the database", "key file"},
+		{exitError(t, "Error: something odd\n"), "gopass show -o failed", "Error: something odd"},
+		{exitError(t, ""), "gopass show -o failed", "exit status 1"},
+		{fmt.Errorf("paste: %w", exec.Command("false").Run()), "A command failed", "exit status 1"},
+		{errors.New("unknown context option 3"), "Error: unknown context option 3", "journalctl"},
+	} {
+		r := errorResult(tc.err)
+		if r.Name != tc.name || !strings.Contains(r.Description, tc.description) || r.IconName != errorIcon {
+			t.Errorf("errorResult(%v) = %+v, want name %q and description containing %q", tc.err, r, tc.name, tc.description)
+		}
+	}
+}
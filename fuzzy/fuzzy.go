@@ -0,0 +1,160 @@
+// Package fuzzy matches queries against entry paths like fzf does: the
+// characters of the query must appear in order in the path, and matches
+// are scored higher when they are consecutive, start path segments or words,
+// and fall in the last segment of the path.
+package fuzzy
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Scores of the matched characters.
+const (
+	scoreMatch = 16
+	// scoreGapStart and scoreGapExtension are subtracted for the first and
+	// each following character skipped between two matches.
+	scoreGapStart     = 3
+	scoreGapExtension = 1
+
+	// bonusSeparator rewards matching the first character of a path segment.
+	bonusSeparator = 10
+	// bonusBoundary rewards matching the first character of a word.
+	bonusBoundary = 8
+	// bonusConsecutive is the minimum bonus of matching right after the
+	// previous match. Consecutive matches otherwise get the bonus of the
+	// first character of their chunk, so that matching a whole segment or
+	// word is worth as much as its first character.
+	bonusConsecutive = 6
+	// bonusBasename rewards matching in the last segment of the path, which
+	// is usually what users remember of an entry.
+	bonusBasename = 2
+)
+
+// Score returns the score of the best match of pattern in text, ignoring
+// case, and false if the characters of pattern don't all appear in text in
+// that order. An empty pattern matches everything with a score of 0.
+func Score(pattern, text string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(strings.ToLower(text))
+	if !isSubsequence(p, t) {
+		return 0, false
+	}
+
+	basename := 0
+	for j, r := range t {
+		if r == '/' {
+			basename = j + 1
+		}
+	}
+	bonus := make([]int, len(t))
+	for j := range t {
+		bonus[j] = charBonus(t, j)
+		if j >= basename {
+			bonus[j] += bonusBasename
+		}
+	}
+
+	// match[j] is the best score of the pattern so far with its last
+	// character matched at j, and chunk[j] the bonus of the first character
+	// of the consecutive matches ending there. gap[j] is the best score with
+	// the last character matched before j, minus the penalty of the gap up to j.
+	const none = -1 << 30
+	match, prevMatch := make([]int, len(t)), make([]int, len(t))
+	chunk, prevChunk := make([]int, len(t)), make([]int, len(t))
+	gap, prevGap := make([]int, len(t)), make([]int, len(t))
+	for i, pr := range p {
+		for j, tr := range t {
+			match[j], gap[j] = none, none
+			if j > 0 {
+				gap[j] = max(match[j-1]-scoreGapStart, gap[j-1]-scoreGapExtension)
+			}
+			if tr != pr {
+				continue
+			}
+			if i == 0 {
+				match[j], chunk[j] = scoreMatch+bonus[j], bonus[j]
+				continue
+			}
+			if j == 0 {
+				continue
+			}
+			consecutive := none
+			if prevMatch[j-1] > none/2 {
+				b := max(bonus[j], prevChunk[j-1], bonusConsecutive)
+				consecutive = prevMatch[j-1] + scoreMatch + b
+			}
+			separated := none
+			if prevGap[j-1] > none/2 {
+				separated = prevGap[j-1] + scoreMatch + bonus[j]
+			}
+			if consecutive >= separated {
+				match[j], chunk[j] = consecutive, prevChunk[j-1]
+			} else {
+				match[j], chunk[j] = separated, bonus[j]
+			}
+		}
+		match, prevMatch = prevMatch, match
+		chunk, prevChunk = prevChunk, chunk
+		gap, prevGap = prevGap, gap
+	}
+	score := none
+	for _, s := range prevMatch {
+		score = max(score, s)
+	}
+	return score, score > none/2
+}
+
+// charBonus returns the bonus of matching the character of t at j.
+func charBonus(t []rune, j int) int {
+	if j == 0 {
+		return bonusBoundary
+	}
+	prev, cur := t[j-1], t[j]
+	switch {
+	case prev == '/':
+		return bonusSeparator
+	case !isWordChar(prev) && isWordChar(cur):
+		return bonusBoundary
+	}
+	return 0
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isSubsequence(p, t []rune) bool {
+	i := 0
+	for _, r := range t {
+		if i < len(p) && p[i] == r {
+			i++
+		}
+	}
+	return i == len(p)
+}
+
+// Match is a text matched by a pattern, with its score.
+type Match struct {
+	Text  string
+	Score int
+}
+
+// Sort sorts matches by decreasing score, then shorter texts first, then
+// alphabetically so that the order is stable across searches.
+func Sort(matches []Match) {
+	slices.SortFunc(matches, func(a, b Match) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(len(a.Text), len(b.Text)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Text, b.Text)
+	})
+}
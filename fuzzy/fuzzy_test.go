@@ -0,0 +1,92 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScoreMatches(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, text string
+		want          bool
+	}{
+		{"", "anything", true},
+		{"ghme", "websites/github.com/me", true},
+		{"GHME", "websites/GitHub.com/me", true},
+		{"gmail", "email/gmail.com/work", true},
+		{"émail", "perso/Émail", true},
+		{"hg", "websites/github.com", false},
+		{"zz", "websites/github.com", false},
+		{"githubx", "websites/github.com", false},
+		{"long pattern", "short", false},
+	} {
+		if _, got := Score(tc.pattern, tc.text); got != tc.want {
+			t.Errorf("Score(%q, %q) matched = %v, want %v", tc.pattern, tc.text, got, tc.want)
+		}
+	}
+}
+
+// better asserts that pattern scores higher on a than on b.
+func better(t *testing.T, pattern, a, b string) {
+	t.Helper()
+	sa, oka := Score(pattern, a)
+	sb, okb := Score(pattern, b)
+	if !oka || !okb {
+		t.Fatalf("%q should match both %q (%v) and %q (%v)", pattern, a, oka, b, okb)
+	}
+	if sa <= sb {
+		t.Errorf("Score(%q, %q) = %d should be higher than Score(%q, %q) = %d", pattern, a, sa, pattern, b, sb)
+	}
+}
+
+func TestScoreRanking(t *testing.T) {
+	// consecutive characters
+	better(t, "git", "websites/github.com", "websites/go-i-t")
+	better(t, "hub", "websites/github.com", "websites/hxuxb")
+	// segment starts
+	better(t, "ghme", "websites/github.com/me", "websites/gohomeme")
+	better(t, "aws", "infra/aws/prod", "infra/laws")
+	// word boundaries
+	better(t, "pc", "work/prod-cluster", "work/epic")
+	// basename
+	better(t, "mail", "perso/mail", "mail/perso")
+	// shorter gaps
+	better(t, "ab", "x/a-b", "x/a-long-gap-b")
+}
+
+func TestScoreBestAlignment(t *testing.T) {
+	// the first 'm' would be a poor match, the matcher must find the one
+	// starting the last segment
+	s1, _ := Score("me", "websites/mail.example/me")
+	s2, _ := Score("me", "websites/me")
+	if s1 != s2 {
+		t.Errorf("Score(me) should only depend on the best alignment: %d != %d", s1, s2)
+	}
+}
+
+func TestSort(t *testing.T) {
+	matches := []Match{
+		{"b/long", 10},
+		{"a/best", 30},
+		{"b/same", 10},
+		{"a/same", 10},
+		{"c", 10},
+	}
+	Sort(matches)
+	want := []Match{
+		{"a/best", 30},
+		{"c", 10},
+		{"a/same", 10},
+		{"b/long", 10},
+		{"b/same", 10},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("Sort = %v, want %v", matches, want)
+	}
+}
+
+func BenchmarkScore(b *testing.B) {
+	for range b.N {
+		Score("ghme", "websites/personal/accounts/github.com/me")
+	}
+}
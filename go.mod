@@ -2,4 +2,9 @@ module github.com/AnomalRoil/cosmic-gopass-plugin
 
 go 1.25.0
 
-require github.com/bendahl/uinput v1.7.0
+require (
+	github.com/bendahl/uinput v1.7.0
+	github.com/godbus/dbus/v5 v5.2.2
+)
+
+require golang.org/x/sys v0.27.0 // indirect
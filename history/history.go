@@ -0,0 +1,207 @@
+// Package history remembers which entries are used, to rank them by
+// frecency: entries used often and recently come first.
+//
+// Entry paths are not stored, only their HMAC-SHA256 with a random key
+// generated for each install and kept next to the history, so that the history
+// alone doesn't leak the content of the password store: predictable paths
+// such as "websites/github.com" can't be found by hashing them.
+package history
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MaxBoost is the maximum score added by Boost, worth about three
+// consecutive characters matched by the fuzzy matcher.
+const MaxBoost = 48
+
+// maxRecords is the number of entries remembered, the least recently used
+// ones are forgotten first.
+const maxRecords = 1000
+
+// Record is the usage of one entry.
+type Record struct {
+	Count   int       `json:"count"`
+	LastUse time.Time `json:"last_use"`
+}
+
+// History is the usage history of the entries, safe for concurrent use.
+type History struct {
+	path string
+	// key is the HMAC key of the entry paths, saved with the history when
+	// newKey is set.
+	key    []byte
+	newKey bool
+	// now is replaced in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+// Path returns the location of the history file.
+func Path() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("locating the state directory: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "cosmic-gopass-plugin", "history.json"), nil
+}
+
+// keyPath returns the location of the HMAC key of the history at path.
+func keyPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "history.key")
+}
+
+// Load reads the history saved at path. A missing file is an empty history.
+// On error, the returned history is empty and still saves to path.
+func Load(path string) (*History, error) {
+	h := &History{path: path, now: time.Now, records: make(map[string]*Record)}
+	key, err := os.ReadFile(keyPath(path))
+	if err == nil && len(key) == sha256.Size {
+		h.key = key
+	} else {
+		// without its key, the records of a previous history can't be used
+		h.key, h.newKey = make([]byte, sha256.Size), true
+		rand.Read(h.key)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return h, fmt.Errorf("reading the history key: %w", err)
+		}
+		return h, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, fmt.Errorf("reading history: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.records); err != nil {
+		h.records = make(map[string]*Record)
+		return h, fmt.Errorf("history %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// hash returns the key of entry in the history.
+func (h *History) hash(entry string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(entry))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Use records that entry was just used and saves the history.
+func (h *History) Use(entry string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.records[h.hash(entry)]
+	if !ok {
+		r = &Record{}
+		h.records[h.hash(entry)] = r
+	}
+	r.Count++
+	r.LastUse = h.now()
+	h.prune()
+	return h.save()
+}
+
+// prune forgets the least recently used entries beyond maxRecords.
+func (h *History) prune() {
+	if len(h.records) <= maxRecords {
+		return
+	}
+	keys := make([]string, 0, len(h.records))
+	for k := range h.records {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return h.records[b].LastUse.Compare(h.records[a].LastUse)
+	})
+	for _, k := range keys[maxRecords:] {
+		delete(h.records, k)
+	}
+}
+
+// save atomically writes the history to its file.
+func (h *History) save() error {
+	data, err := json.Marshal(h.records)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+		return fmt.Errorf("saving history: %w", err)
+	}
+	if h.newKey {
+		if err := os.WriteFile(keyPath(h.path), h.key, 0o600); err != nil {
+			return fmt.Errorf("saving the history key: %w", err)
+		}
+		h.newKey = false
+	}
+	f, err := os.CreateTemp(filepath.Dir(h.path), ".history-*.json")
+	if err != nil {
+		return fmt.Errorf("saving history: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("saving history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("saving history: %w", err)
+	}
+	if err := os.Rename(f.Name(), h.path); err != nil {
+		return fmt.Errorf("saving history: %w", err)
+	}
+	return nil
+}
+
+// Frecency returns how often and recently entry was used: its use count
+// weighted by how long ago it was last used.
+func (h *History) Frecency(entry string) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.records[h.hash(entry)]
+	if !ok {
+		return 0
+	}
+	age := h.now().Sub(r.LastUse)
+	weight := 0.1
+	switch {
+	case age < 4*24*time.Hour:
+		weight = 1
+	case age < 14*24*time.Hour:
+		weight = 0.7
+	case age < 31*24*time.Hour:
+		weight = 0.5
+	case age < 90*24*time.Hour:
+		weight = 0.3
+	}
+	return float64(r.Count) * weight
+}
+
+// Boost returns the score to add to the fuzzy match score of entry, between
+// 0 and MaxBoost. It grows logarithmically with the frecency, so that matching
+// the query well still matters more than having been used a lot.
+func (h *History) Boost(entry string) int {
+	f := h.Frecency(entry)
+	if f == 0 {
+		return 0
+	}
+	return min(MaxBoost, int(8*math.Log2(1+f)))
+}
@@ -0,0 +1,152 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock is a fake time source.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func load(t *testing.T, path string) (*History, *clock) {
+	t.Helper()
+	h, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	c := &clock{time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	h.now = c.now
+	return h, c
+}
+
+func TestUsePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "history.json")
+	h, _ := load(t, path)
+	for _, e := range []string{"websites/github.com", "websites/github.com", "email/work"} {
+		if err := h.Use(e); err != nil {
+			t.Fatalf("Use: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "github") {
+		t.Errorf("the history should not contain entry names: %s", data)
+	}
+
+	h2, _ := load(t, path)
+	if got := h2.Frecency("websites/github.com"); got != 2 {
+		t.Errorf("Frecency = %v, want 2", got)
+	}
+	if got := h2.Frecency("email/work"); got != 1 {
+		t.Errorf("Frecency = %v, want 1", got)
+	}
+	if got := h2.Frecency("unused"); got != 0 {
+		t.Errorf("Frecency = %v, want 0", got)
+	}
+}
+
+func TestKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history.json")
+	h, _ := load(t, path)
+	h.Use("websites/github.com")
+	if info, err := os.Stat(filepath.Join(dir, "history.key")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("history key = %v, %v, want a file only readable by the user", info, err)
+	}
+	// the plain hash of a path doesn't find it in the history
+	sum := sha256.Sum256([]byte("websites/github.com"))
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), hex.EncodeToString(sum[:])) {
+		t.Errorf("the history should not contain unkeyed hashes: %s", data)
+	}
+
+	// each install has its own key, and the records can't be used without it
+	other, _ := load(t, filepath.Join(t.TempDir(), "history.json"))
+	if other.hash("websites/github.com") == h.hash("websites/github.com") {
+		t.Error("two installs should have different keys")
+	}
+	os.Remove(filepath.Join(dir, "history.key"))
+	if h, _ := load(t, path); h.Frecency("websites/github.com") != 0 {
+		t.Error("the history should start over without its key")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	h, err := Load(filepath.Join(dir, "missing.json"))
+	if err != nil || h == nil {
+		t.Fatalf("a missing history should be empty, got %v", err)
+	}
+
+	path := filepath.Join(dir, "history.json")
+	if err := h.Use("a"); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	os.WriteFile(path, []byte("{"), 0o600)
+	h, err = Load(path)
+	if err == nil {
+		t.Fatal("Load should fail on a corrupted history")
+	}
+	// the history is usable and replaces the corrupted file
+	if err := h.Use("a"); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Errorf("Load after Use: %v", err)
+	}
+}
+
+func TestFrecencyDecays(t *testing.T) {
+	h, c := load(t, filepath.Join(t.TempDir(), "history.json"))
+	for range 10 {
+		h.Use("old")
+	}
+	c.t = c.t.Add(60 * 24 * time.Hour)
+	for range 4 {
+		h.Use("recent")
+	}
+	if old, recent := h.Frecency("old"), h.Frecency("recent"); old >= recent {
+		t.Errorf("frecency of old entry %v should be lower than recent entry %v", old, recent)
+	}
+}
+
+func TestBoost(t *testing.T) {
+	h, _ := load(t, filepath.Join(t.TempDir(), "history.json"))
+	if b := h.Boost("a"); b != 0 {
+		t.Errorf("Boost of an unused entry = %d, want 0", b)
+	}
+	h.Use("a")
+	once := h.Boost("a")
+	for range 1000 {
+		h.records[h.hash("a")].Count++
+	}
+	if many := h.Boost("a"); once <= 0 || many <= once || many != MaxBoost {
+		t.Errorf("Boost = %d once, %d after many uses", once, many)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	h, c := load(t, filepath.Join(t.TempDir(), "history.json"))
+	h.Use("first")
+	for i := range maxRecords {
+		c.t = c.t.Add(time.Second)
+		h.records[h.hash(string(rune(i)))] = &Record{Count: 1, LastUse: c.t}
+	}
+	c.t = c.t.Add(time.Second)
+	h.Use("last")
+	if len(h.records) != maxRecords {
+		t.Errorf("%d records, want %d", len(h.records), maxRecords)
+	}
+	if h.Frecency("first") != 0 || h.Frecency("last") == 0 {
+		t.Error("the least recently used entry should be forgotten")
+	}
+}
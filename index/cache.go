@@ -0,0 +1,63 @@
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CachePath returns the location of the cache of the entries.
+func CachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("locating the cache directory: %w", err)
+	}
+	return filepath.Join(dir, "cosmic-gopass-plugin", "entries.json"), nil
+}
+
+// LoadCache reads the entries saved at path by SaveCache. A missing cache
+// has no entries.
+func LoadCache(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading the entry cache: %w", err)
+	}
+	var entries []string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("entry cache %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// SaveCache atomically saves entries at path, only readable by the user
+// since the entry names may be sensitive.
+func SaveCache(path string, entries []string) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("saving the entry cache: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".entries-*.json")
+	if err != nil {
+		return fmt.Errorf("saving the entry cache: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("saving the entry cache: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("saving the entry cache: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("saving the entry cache: %w", err)
+	}
+	return nil
+}
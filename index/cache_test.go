@@ -0,0 +1,39 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "entries.json")
+	if entries, err := LoadCache(path); err != nil || entries != nil {
+		t.Fatalf("LoadCache of a missing cache = %q, %v", entries, err)
+	}
+
+	want := []string{"websites/github.com", "email/work"}
+	if err := SaveCache(path, want); err != nil {
+		t.Fatalf("SaveCache: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("cache mode = %v, %v, want 0600", info.Mode(), err)
+	}
+	got, err := LoadCache(path)
+	if err != nil || !slices.Equal(got, want) {
+		t.Errorf("LoadCache = %q, %v, want %q", got, err, want)
+	}
+
+	os.WriteFile(path, []byte("not json"), 0o600)
+	if _, err := LoadCache(path); err == nil {
+		t.Error("LoadCache should fail on a corrupted cache")
+	}
+}
+
+func TestCachePath(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/tmp/cache")
+	if got, err := CachePath(); err != nil || got != "/tmp/cache/cosmic-gopass-plugin/entries.json" {
+		t.Errorf("CachePath = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,78 @@
+// Package index holds the entries searched by the plugin, updated while
+// searches read them.
+package index
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Index is a set of entries, safe for concurrent use. Readers get immutable
+// snapshots of the entries, which updates replace atomically.
+type Index struct {
+	// mu serialises the updates.
+	mu      sync.Mutex
+	entries atomic.Pointer[map[string]string]
+}
+
+// New returns an index of entries.
+func New(entries []string) *Index {
+	x := &Index{}
+	x.Set(entries)
+	return x
+}
+
+// Entries returns the entries, by their lower case version to search them
+// without converting them on each search. The map must not be modified.
+func (x *Index) Entries() map[string]string {
+	return *x.entries.Load()
+}
+
+// Len returns the number of entries.
+func (x *Index) Len() int {
+	return len(x.Entries())
+}
+
+// Set replaces all the entries.
+func (x *Index) Set(entries []string) {
+	m := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if e != "" {
+			m[strings.ToLower(e)] = e
+		}
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.entries.Store(&m)
+}
+
+// Update removes then adds entries. Removed entries ending with "/" are
+// folders, all the entries of which are removed.
+func (x *Index) Update(added, removed []string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	old := x.Entries()
+	m := make(map[string]string, len(old)+len(added))
+	for lower, e := range old {
+		m[lower] = e
+	}
+	for _, e := range removed {
+		lower := strings.ToLower(e)
+		if !strings.HasSuffix(lower, "/") {
+			delete(m, lower)
+			continue
+		}
+		for l := range m {
+			if strings.HasPrefix(l, lower) {
+				delete(m, l)
+			}
+		}
+	}
+	for _, e := range added {
+		if e != "" {
+			m[strings.ToLower(e)] = e
+		}
+	}
+	x.entries.Store(&m)
+}
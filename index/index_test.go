@@ -0,0 +1,60 @@
+package index
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func values(x *Index) []string {
+	return slices.Sorted(maps.Values(x.Entries()))
+}
+
+func TestIndex(t *testing.T) {
+	x := New([]string{"websites/GitHub.com", "email/work", "email/perso", ""})
+	if got := x.Entries()["websites/github.com"]; got != "websites/GitHub.com" {
+		t.Errorf("entries are not indexed by lower case: %q", got)
+	}
+	if x.Len() != 3 {
+		t.Errorf("Len = %d, want 3", x.Len())
+	}
+
+	snapshot := x.Entries()
+	x.Update([]string{"email/new", "infra/aws"}, []string{"websites/github.com", "missing"})
+	if got, want := values(x), []string{"email/new", "email/perso", "email/work", "infra/aws"}; !slices.Equal(got, want) {
+		t.Errorf("after Update: %q, want %q", got, want)
+	}
+	if len(snapshot) != 3 {
+		t.Errorf("Update modified a snapshot: %q", snapshot)
+	}
+
+	// folders are removed before entries are added, which may be in them
+	x.Update([]string{"email/moved"}, []string{"Email/"})
+	if got, want := values(x), []string{"email/moved", "infra/aws"}; !slices.Equal(got, want) {
+		t.Errorf("after removing a folder: %q, want %q", got, want)
+	}
+
+	x.Set([]string{"a"})
+	if got := values(x); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("after Set: %q", got)
+	}
+}
+
+func TestIndexConcurrent(t *testing.T) {
+	x := New(nil)
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Go(func() {
+			x.Update([]string{string(rune('a' + i))}, nil)
+		})
+		wg.Go(func() {
+			for range x.Entries() {
+			}
+		})
+	}
+	wg.Wait()
+	if x.Len() != 10 {
+		t.Errorf("Len = %d after concurrent updates, want 10", x.Len())
+	}
+}
@@ -0,0 +1,166 @@
+// Package install deploys the plugin where pop-launcher looks for plugins,
+// with a plugin.ron generated from the configuration so that the launcher
+// only sends the queries the plugin handles.
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/config"
+)
+
+const (
+	// PluginName is the directory of the plugin inside the plugins directory.
+	PluginName = "gopass"
+	// BinaryName is the name of the installed binary, referenced by plugin.ron.
+	BinaryName = "cosmic-gopass-plugin"
+	// RONName is the name of the plugin description file.
+	RONName = "plugin.ron"
+
+	// systemPrefix is where pop-launcher looks for system-wide plugins.
+	systemPrefix = "/usr/lib"
+)
+
+// Dir returns the directory the plugin is installed in: the pop-launcher
+// plugins directory of prefix, which defaults to $XDG_DATA_HOME for the current
+// user, or to /usr/lib for a system-wide install.
+func Dir(prefix string, system bool) (string, error) {
+	if prefix == "" && system {
+		prefix = systemPrefix
+	}
+	if prefix == "" {
+		prefix = os.Getenv("XDG_DATA_HOME")
+	}
+	if prefix == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("locating the data directory: %w", err)
+		}
+		prefix = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(prefix, "pop-launcher", "plugins", PluginName), nil
+}
+
+// Regex returns the launcher query regex matching the prefixes of cfg.
+func Regex(cfg *config.Config) string {
+	return "^(" + regexp.QuoteMeta(cfg.Prefix) + "|" + regexp.QuoteMeta(cfg.OTPPrefix) + ")"
+}
+
+// PluginRON returns the plugin.ron describing the plugin configured by cfg.
+func PluginRON(cfg *config.Config) string {
+	prefix, otpPrefix := strings.TrimSpace(cfg.Prefix), strings.TrimSpace(cfg.OTPPrefix)
+	description := fmt.Sprintf("Syntax: %s <query> or %s <query>\nCopy password or OTP code from gopass to clipboard", prefix, otpPrefix)
+	var b strings.Builder
+	b.WriteString("(\n")
+	b.WriteString("    name: \"Gopass\",\n")
+	fmt.Fprintf(&b, "    description: %s,\n", ronString(description))
+	b.WriteString("    query: (\n")
+	fmt.Fprintf(&b, "        regex: %s,\n", ronString(Regex(cfg)))
+	fmt.Fprintf(&b, "        help: %s,\n", ronString(cfg.Prefix))
+	b.WriteString("        isolate: true,\n")
+	b.WriteString("        no_sort: true,\n")
+	b.WriteString("        history: false,\n")
+	b.WriteString("    ),\n")
+	fmt.Fprintf(&b, "    bin: (path: %s),\n", ronString(BinaryName))
+	fmt.Fprintf(&b, "    icon: Name(%s),\n", ronString(cfg.Icon))
+	b.WriteString(")\n")
+	return b.String()
+}
+
+// ronString quotes s as a RON string literal.
+func ronString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(s) + `"`
+}
+
+// Install copies the binary at bin into dir along with a plugin.ron generated
+// from cfg, replacing any previous installation.
+func Install(dir, bin string, cfg *config.Config) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating the plugin directory: %w", err)
+	}
+	src, err := os.Open(bin)
+	if err != nil {
+		return fmt.Errorf("opening the plugin binary: %w", err)
+	}
+	defer src.Close()
+	if err := writeFile(filepath.Join(dir, BinaryName), src, 0o755); err != nil {
+		return fmt.Errorf("installing the plugin binary: %w", err)
+	}
+	if err := writeFile(filepath.Join(dir, RONName), strings.NewReader(PluginRON(cfg)), 0o644); err != nil {
+		return fmt.Errorf("installing %s: %w", RONName, err)
+	}
+	return nil
+}
+
+// writeFile atomically replaces path with the content of r, so that a running
+// plugin is never executed half-written.
+func writeFile(path string, r io.Reader, perm fs.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Uninstall removes the files installed in dir by Install, and dir itself
+// unless something else was put in it.
+func Uninstall(dir string) error {
+	var errs []error
+	for _, name := range []string{RONName, BinaryName} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if err := os.Remove(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+			// leave the files we don't know about
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// InstalledRegex returns the query regex of the plugin.ron installed in dir.
+func InstalledRegex(dir string) (string, error) {
+	ron, err := os.ReadFile(filepath.Join(dir, RONName))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(ron), "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "regex:")
+		if !ok {
+			continue
+		}
+		// RON string literals use the same escapes as Go for what PluginRON writes
+		regex, err := strconv.Unquote(strings.TrimSuffix(strings.TrimSpace(value), ","))
+		if err != nil {
+			return "", fmt.Errorf("invalid regex in %s: %w", RONName, err)
+		}
+		return regex, nil
+	}
+	return "", fmt.Errorf("no query regex in %s", RONName)
+}
@@ -0,0 +1,181 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/config"
+)
+
+func TestDir(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/data")
+	t.Setenv("HOME", "/home/me")
+	for _, tc := range []struct {
+		prefix string
+		system bool
+		want   string
+	}{
+		{"", false, "/data/pop-launcher/plugins/gopass"},
+		{"", true, "/usr/lib/pop-launcher/plugins/gopass"},
+		{"/opt", false, "/opt/pop-launcher/plugins/gopass"},
+		{"/opt", true, "/opt/pop-launcher/plugins/gopass"},
+	} {
+		got, err := Dir(tc.prefix, tc.system)
+		if err != nil || got != tc.want {
+			t.Errorf("Dir(%q, %v) = %q, %v, want %q", tc.prefix, tc.system, got, err, tc.want)
+		}
+	}
+
+	t.Setenv("XDG_DATA_HOME", "")
+	if got, _ := Dir("", false); got != "/home/me/.local/share/pop-launcher/plugins/gopass" {
+		t.Errorf("Dir without XDG_DATA_HOME = %q", got)
+	}
+}
+
+func TestDefaultPluginRONIsCheckedIn(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join("..", "plugins", "gopass", RONName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := PluginRON(config.Default()); got != string(want) {
+		t.Errorf("plugins/gopass/plugin.ron is out of date, want:\n%s", got)
+	}
+}
+
+func TestRegex(t *testing.T) {
+	cfg := config.Default()
+	cfg.Prefix = "pw. "
+	cfg.OTPPrefix = "2fa "
+	re := regexp.MustCompile(Regex(cfg))
+	for query, want := range map[string]bool{
+		"pw. github":  true,
+		"2fa github":  true,
+		"pwx github":  false,
+		"gp github":   false,
+		"x pw. query": false,
+	} {
+		if got := re.MatchString(query); got != want {
+			t.Errorf("%q matching %q = %v, want %v", Regex(cfg), query, got, want)
+		}
+	}
+
+	ron := PluginRON(cfg)
+	if !strings.Contains(ron, `regex: "^(pw\\. |2fa )",`) || !strings.Contains(ron, `help: "pw. ",`) {
+		t.Errorf("unexpected plugin.ron:\n%s", ron)
+	}
+}
+
+func TestInstallUninstall(t *testing.T) {
+	tmp := t.TempDir()
+	bin := filepath.Join(tmp, "build")
+	if err := os.WriteFile(bin, []byte("binary v1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(tmp, "plugins", "gopass")
+	cfg := config.Default()
+	if err := Install(dir, bin, cfg); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	checkFile(t, filepath.Join(dir, BinaryName), "binary v1", 0o755)
+	checkFile(t, filepath.Join(dir, RONName), PluginRON(cfg), 0o644)
+
+	// installing again replaces the previous version
+	if err := os.WriteFile(bin, []byte("binary v2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Prefix = "pw "
+	if err := Install(dir, bin, cfg); err != nil {
+		t.Fatalf("Install again: %v", err)
+	}
+	checkFile(t, filepath.Join(dir, BinaryName), "binary v2", 0o755)
+	checkFile(t, filepath.Join(dir, RONName), PluginRON(cfg), 0o644)
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("unexpected files left in %s: %v", dir, entries)
+	}
+
+	if err := Uninstall(dir); err != nil {
+		t.Fatalf("Uninstall: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("%s still exists after Uninstall: %v", dir, err)
+	}
+	// uninstalling twice is fine
+	if err := Uninstall(dir); err != nil {
+		t.Errorf("Uninstall again: %v", err)
+	}
+}
+
+func TestUninstallKeepsOtherFiles(t *testing.T) {
+	tmp := t.TempDir()
+	bin := filepath.Join(tmp, "build")
+	if err := os.WriteFile(bin, []byte("binary"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(tmp, "gopass")
+	if err := Install(dir, bin, config.Default()); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	other := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(other, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Uninstall(dir); err != nil {
+		t.Fatalf("Uninstall: %v", err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("Uninstall removed %s: %v", other, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, BinaryName)); !os.IsNotExist(err) {
+		t.Errorf("binary still installed: %v", err)
+	}
+}
+
+func TestInstallMissingBinary(t *testing.T) {
+	dir := t.TempDir()
+	if err := Install(dir, filepath.Join(dir, "missing"), config.Default()); err == nil {
+		t.Error("Install should fail without a binary")
+	}
+}
+
+func TestInstalledRegex(t *testing.T) {
+	tmp := t.TempDir()
+	bin := filepath.Join(tmp, "build")
+	if err := os.WriteFile(bin, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Prefix = `p\w "`
+	if err := Install(tmp, bin, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := InstalledRegex(tmp); err != nil || got != Regex(cfg) {
+		t.Errorf("InstalledRegex = %q, %v, want %q", got, err, Regex(cfg))
+	}
+
+	if err := os.WriteFile(filepath.Join(tmp, RONName), []byte("(\n    name: \"Gopass\",\n)\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InstalledRegex(tmp); err == nil {
+		t.Error("InstalledRegex should fail without a regex")
+	}
+	if _, err := InstalledRegex(filepath.Join(tmp, "missing")); !os.IsNotExist(err) {
+		t.Errorf("InstalledRegex of a missing plugin = %v, want a not exist error", err)
+	}
+}
+
+func checkFile(t *testing.T, path, content string, perm os.FileMode) {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != perm {
+		t.Errorf("%s has mode %v, want %v", path, fi.Mode().Perm(), perm)
+	}
+	if b, _ := os.ReadFile(path); string(b) != content {
+		t.Errorf("%s = %q, want %q", path, b, content)
+	}
+}
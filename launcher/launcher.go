@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,6 +18,20 @@ type SearchResult struct {
 	Name        string
 	Description string
 	IconName    string // optional; if empty, no icon is sent
+	// Disabled results, such as errors, can't be activated, completed nor
+	// have a context menu.
+	Disabled bool
+	// Fill is optional; activating a result with Fill set fills the launcher
+	// query with it, such as to navigate into a folder, instead of calling
+	// OnActivate. Completing it does the same instead of calling OnComplete,
+	// and it has no context menu.
+	Fill string
+}
+
+// ContextOption is a single item of a result's context menu.
+type ContextOption struct {
+	ID   uint32
+	Name string
 }
 
 // Config configures a launcher plugin.
@@ -26,6 +41,19 @@ type Config struct {
 	Logger     *log.Logger // if nil, logging is discarded
 	OnSearch   func(ctx context.Context, query string, appendResult func(SearchResult)) error
 	OnActivate func(entry string) error
+	// OnComplete is optional; it receives the last search query and the selected
+	// entry and returns the text the launcher query should be filled with.
+	OnComplete func(query, entry string) string
+	// OnContext is optional; it returns the context menu options of an entry.
+	// OnActivateContext must be set along with it and receives the chosen option ID.
+	OnContext         func(entry string) ([]ContextOption, error)
+	OnActivateContext func(entry string, optionID uint32) error
+	// OnError is optional; it returns the result displaying an error to the user.
+	// Without it, errors are only logged. With it, failed searches end with an
+	// error result, and failed activations and context menus fill the launcher
+	// with the last query again so that the following search shows the error
+	// first, instead of closing the launcher.
+	OnError func(err error) SearchResult
 }
 
 // LoadConfig validates required callbacks and prevents runtime panics and loads the config.
@@ -46,6 +74,9 @@ func (c *Config) LoadConfig() (*log.Logger, io.Reader, io.Writer, error) {
 	if c.OnActivate == nil {
 		return l, nil, nil, fmt.Errorf("config OnActivate callback is required")
 	}
+	if c.OnContext != nil && c.OnActivateContext == nil {
+		return l, nil, nil, fmt.Errorf("config OnActivateContext callback is required when OnContext is set")
+	}
 
 	stdin := c.Stdin
 	if stdin == nil {
@@ -71,7 +102,9 @@ func Run(cfg Config) {
 	var (
 		outputMu     sync.Mutex
 		resultsMu    sync.Mutex
-		lastResults  []string
+		lastResults  []SearchResult
+		lastQuery    string
+		pendingErr   error
 		searchCancel context.CancelFunc
 		searchDone   chan struct{}
 	)
@@ -96,6 +129,35 @@ func Run(cfg Config) {
 		fmt.Fprintln(stdout, s)
 	}
 
+	// lookup returns the result of the given ID from the last search.
+	lookup := func(kind string, id uint32) (SearchResult, bool) {
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		if int(id) >= len(lastResults) {
+			l.Printf("ERROR: %s id=%d out of range (have %d results)", kind, id, len(lastResults))
+			return SearchResult{}, false
+		}
+		if lastResults[id].Disabled {
+			l.Printf("Ignoring %s of disabled result id=%d", kind, id)
+		}
+		return lastResults[id], true
+	}
+
+	// reportError reports a failed request, closing the launcher unless the
+	// error can be displayed by searching the last query again.
+	reportError := func(kind string, err error) {
+		l.Printf("ERROR: %s failed: %v", kind, err)
+		if cfg.OnError == nil {
+			respondRaw(`"Close"`)
+			return
+		}
+		resultsMu.Lock()
+		pendingErr = err
+		query := lastQuery
+		resultsMu.Unlock()
+		respond(fillResponse{Fill: query})
+	}
+
 	cancelSearch := func() {
 		if searchCancel != nil {
 			searchCancel()
@@ -160,7 +222,7 @@ func Run(cfg Config) {
 
 				respondRaw(`"Clear"`)
 
-				var matched []string
+				var matched []SearchResult
 
 				appendResult := func(sr SearchResult) {
 					var icon *iconSource
@@ -175,36 +237,111 @@ func Run(cfg Config) {
 							Icon:        icon,
 						},
 					})
-					matched = append(matched, sr.Name)
+					matched = append(matched, sr)
+				}
+				appendError := func(err error) {
+					if cfg.OnError != nil {
+						sr := cfg.OnError(err)
+						sr.Disabled = true
+						appendResult(sr)
+					}
+				}
+
+				resultsMu.Lock()
+				failed := pendingErr
+				pendingErr = nil
+				resultsMu.Unlock()
+				if failed != nil {
+					appendError(failed)
 				}
 
-				if err := cfg.OnSearch(ctx, query, appendResult); err != nil {
+				if err := cfg.OnSearch(ctx, query, appendResult); err != nil && !errors.Is(err, context.Canceled) {
 					l.Printf("ERROR: search failed: %v", err)
+					appendError(err)
 				}
 
 				resultsMu.Lock()
 				lastResults = matched
+				lastQuery = query
 				resultsMu.Unlock()
 			}(ctx, query)
 
 		case req.Activate != nil:
 			cancelSearch()
 
-			id := int(*req.Activate)
+			result, ok := lookup("Activate", *req.Activate)
+			if !ok {
+				respondRaw(`"Close"`)
+				continue
+			}
+			if result.Disabled {
+				// keep the launcher open, to let the user read the error
+				continue
+			}
+			if result.Fill != "" {
+				respond(fillResponse{Fill: result.Fill})
+				continue
+			}
+
+			if err := cfg.OnActivate(result.Name); err != nil {
+				reportError("activate", err)
+				continue
+			}
+			respondRaw(`"Close"`)
+
+		case req.Complete != nil && cfg.OnComplete != nil:
+			cancelSearch()
+
+			result, ok := lookup("Complete", *req.Complete)
+			if !ok || result.Disabled {
+				continue
+			}
+			if result.Fill != "" {
+				respond(fillResponse{Fill: result.Fill})
+				continue
+			}
 			resultsMu.Lock()
-			var entry string
-			if id < len(lastResults) {
-				entry = lastResults[id]
-				resultsMu.Unlock()
-			} else {
-				l.Printf("ERROR: Activate id=%d out of range (have %d results)", id, len(lastResults))
-				resultsMu.Unlock()
+			query := lastQuery
+			resultsMu.Unlock()
+
+			respond(fillResponse{Fill: cfg.OnComplete(query, result.Name)})
+
+		case req.Context != nil && cfg.OnContext != nil:
+			cancelSearch()
+
+			result, ok := lookup("Context", *req.Context)
+			if !ok || result.Disabled || result.Fill != "" {
+				continue
+			}
+
+			options, err := cfg.OnContext(result.Name)
+			if err != nil {
+				reportError("context", err)
+				continue
+			}
+			resp := contextResponse{}
+			resp.Context.ID = *req.Context
+			resp.Context.Options = []contextOption{}
+			for _, opt := range options {
+				resp.Context.Options = append(resp.Context.Options, contextOption(opt))
+			}
+			respond(resp)
+
+		case req.ActivateContext != nil && cfg.OnContext != nil:
+			cancelSearch()
+
+			result, ok := lookup("ActivateContext", req.ActivateContext.ID)
+			if !ok {
 				respondRaw(`"Close"`)
 				continue
 			}
+			if result.Disabled || result.Fill != "" {
+				continue
+			}
 
-			if err := cfg.OnActivate(entry); err != nil {
-				l.Printf("ERROR: activate failed: %v", err)
+			if err := cfg.OnActivateContext(result.Name, req.ActivateContext.Context); err != nil {
+				reportError("activate context", err)
+				continue
 			}
 			respondRaw(`"Close"`)
 
@@ -221,6 +358,13 @@ type request struct {
 	Activate *uint32 `json:"Activate,omitempty"`
 	Complete *uint32 `json:"Complete,omitempty"`
 	Context  *uint32 `json:"Context,omitempty"`
+
+	ActivateContext *activateContextRequest `json:"ActivateContext,omitempty"`
+}
+
+type activateContextRequest struct {
+	ID      uint32 `json:"id"`
+	Context uint32 `json:"context"`
 }
 
 type iconSource struct {
@@ -237,3 +381,19 @@ type pluginSearchResult struct {
 type appendResponse struct {
 	Append pluginSearchResult `json:"Append"`
 }
+
+type fillResponse struct {
+	Fill string `json:"Fill"`
+}
+
+type contextOption struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"`
+}
+
+type contextResponse struct {
+	Context struct {
+		ID      uint32          `json:"id"`
+		Options []contextOption `json:"options"`
+	} `json:"Context"`
+}
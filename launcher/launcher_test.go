@@ -44,17 +44,24 @@ func runTrace(
 ) (outputLines []string, logOutput string) {
 	t.Helper()
 
-	stdin := strings.NewReader(strings.Join(input, "\n") + "\n")
-	var stdout safeWriter
-	var logBuf strings.Builder
-
-	Run(Config{
-		Stdin:      stdin,
-		Stdout:     &stdout,
-		Logger:     log.New(&logBuf, "", 0),
+	return runConfig(t, input, Config{
 		OnSearch:   onSearch,
 		OnActivate: onActivate,
 	})
+}
+
+// runConfig is like runTrace but lets the caller set the optional callbacks.
+// Stdin, Stdout and Logger of cfg are overwritten.
+func runConfig(t *testing.T, input []string, cfg Config) (outputLines []string, logOutput string) {
+	t.Helper()
+
+	var stdout safeWriter
+	var logBuf strings.Builder
+
+	cfg.Stdin = strings.NewReader(strings.Join(input, "\n") + "\n")
+	cfg.Stdout = &stdout
+	cfg.Logger = log.New(&logBuf, "", 0)
+	Run(cfg)
 
 	return stdout.Lines(), logBuf.String()
 }
@@ -149,6 +156,18 @@ func TestLoadConfigCustomValues(t *testing.T) {
 	}
 }
 
+func TestLoadConfigContextWithoutActivateContext(t *testing.T) {
+	c := &Config{
+		OnSearch:   func(context.Context, string, func(SearchResult)) error { return nil },
+		OnActivate: func(string) error { return nil },
+		OnContext:  func(string) ([]ContextOption, error) { return nil, nil },
+	}
+	_, _, _, err := c.LoadConfig()
+	if err == nil || !strings.Contains(err.Error(), "OnActivateContext") {
+		t.Fatalf("expected OnActivateContext error, got: %v", err)
+	}
+}
+
 func TestRunWithInvalidConfig(t *testing.T) {
 	var logBuf strings.Builder
 	Run(Config{
@@ -443,7 +462,7 @@ func TestActivateCallbackError(t *testing.T) {
 
 func TestUnhandledRequest(t *testing.T) {
 	got, logOut := runTrace(t,
-		// Input trace: Complete is parsed but not handled.
+		// Input trace: Complete is parsed but not handled without OnComplete.
 		[]string{
 			`{"Complete":0}`,
 			`"Exit"`,
@@ -467,6 +486,363 @@ func TestUnhandledRequest(t *testing.T) {
 	}
 }
 
+func TestSearchCompleteExit(t *testing.T) {
+	var gotQuery, gotEntry string
+	got, _ := runConfig(t,
+		// Input trace:
+		[]string{
+			`{"Search":"gp web"}`,
+			`{"Complete":1}`,
+			`"Exit"`,
+		},
+		Config{
+			OnSearch: func(ctx context.Context, q string, add func(SearchResult)) error {
+				add(SearchResult{Name: "websites/github.com/me", Description: "github"})
+				add(SearchResult{Name: "websites/gitlab.com/me", Description: "gitlab"})
+				return nil
+			},
+			OnActivate: func(entry string) error {
+				t.Error("OnActivate called unexpectedly")
+				return nil
+			},
+			OnComplete: func(query, entry string) string {
+				gotQuery, gotEntry = query, entry
+				return "gp websites/"
+			},
+		},
+	)
+
+	if gotQuery != "gp web" || gotEntry != "websites/gitlab.com/me" {
+		t.Errorf("OnComplete(%q, %q), want (%q, %q)", gotQuery, gotEntry, "gp web", "websites/gitlab.com/me")
+	}
+
+	// Expected output trace:
+	assertLines(t, got, []string{
+		`"Clear"`,
+		`{"Append":{"id":0,"name":"websites/github.com/me","description":"github"}}`,
+		`{"Append":{"id":1,"name":"websites/gitlab.com/me","description":"gitlab"}}`,
+		`"Finished"`,
+		`{"Fill":"gp websites/"}`,
+		`"Finished"`,
+	})
+}
+
+func TestActivateFillResult(t *testing.T) {
+	got, _ := runConfig(t,
+		// Input trace:
+		[]string{
+			`{"Search":"gp websites/"}`,
+			`{"Activate":0}`,
+			`{"Complete":0}`,
+			`{"Context":0}`,
+			`"Exit"`,
+		},
+		Config{
+			OnSearch: func(ctx context.Context, q string, add func(SearchResult)) error {
+				add(SearchResult{Name: "websites/github.com/", Description: "2 entries", Fill: "gp websites/github.com/"})
+				return nil
+			},
+			OnActivate: func(entry string) error {
+				t.Error("OnActivate called unexpectedly")
+				return nil
+			},
+			OnComplete: func(query, entry string) string {
+				t.Error("OnComplete called unexpectedly")
+				return ""
+			},
+			OnContext: func(entry string) ([]ContextOption, error) {
+				t.Error("OnContext called unexpectedly")
+				return nil, nil
+			},
+			OnActivateContext: func(entry string, optionID uint32) error { return nil },
+		},
+	)
+
+	// Expected output trace: no "Close", the query is filled instead, and no context menu.
+	assertLines(t, got, []string{
+		`"Clear"`,
+		`{"Append":{"id":0,"name":"websites/github.com/","description":"2 entries"}}`,
+		`"Finished"`,
+		`{"Fill":"gp websites/github.com/"}`,
+		`{"Fill":"gp websites/github.com/"}`,
+		`"Finished"`,
+	})
+}
+
+func TestCompleteOutOfRange(t *testing.T) {
+	got, logOut := runConfig(t,
+		// Input trace:
+		[]string{
+			`{"Search":"q"}`,
+			`{"Complete":5}`,
+			`"Exit"`,
+		},
+		Config{
+			OnSearch: func(ctx context.Context, q string, add func(SearchResult)) error {
+				add(SearchResult{Name: "only-one", Description: "single result"})
+				return nil
+			},
+			OnActivate: func(entry string) error { return nil },
+			OnComplete: func(query, entry string) string {
+				t.Error("OnComplete should not be called for out-of-range ID")
+				return ""
+			},
+		},
+	)
+
+	// Expected output trace: no Fill is sent.
+	assertLines(t, got, []string{
+		`"Clear"`,
+		`{"Append":{"id":0,"name":"only-one","description":"single result"}}`,
+		`"Finished"`,
+		`"Finished"`,
+	})
+
+	if !strings.Contains(logOut, "out of range") {
+		t.Errorf("log should mention 'out of range', got: %s", logOut)
+	}
+}
+
+func TestSearchContextActivateContext(t *testing.T) {
+	var contextEntry, activatedEntry string
+	var activatedOption uint32
+	got, _ := runConfig(t,
+		// Input trace:
+		[]string{
+			`{"Search":"gp email"}`,
+			`{"Context":1}`,
+			`{"ActivateContext":{"id":1,"context":2}}`,
+			`"Exit"`,
+		},
+		Config{
+			OnSearch: func(ctx context.Context, q string, add func(SearchResult)) error {
+				add(SearchResult{Name: "Email/personal", Description: "personal email"})
+				add(SearchResult{Name: "Email/work", Description: "work email"})
+				return nil
+			},
+			OnActivate: func(entry string) error {
+				t.Error("OnActivate called unexpectedly")
+				return nil
+			},
+			OnContext: func(entry string) ([]ContextOption, error) {
+				contextEntry = entry
+				return []ContextOption{
+					{ID: 0, Name: "Copy username"},
+					{ID: 2, Name: "Open URL"},
+				}, nil
+			},
+			OnActivateContext: func(entry string, optionID uint32) error {
+				activatedEntry, activatedOption = entry, optionID
+				return nil
+			},
+		},
+	)
+
+	if contextEntry != "Email/work" {
+		t.Errorf("context entry = %q, want %q", contextEntry, "Email/work")
+	}
+	if activatedEntry != "Email/work" || activatedOption != 2 {
+		t.Errorf("activated (%q, %d), want (%q, %d)", activatedEntry, activatedOption, "Email/work", 2)
+	}
+
+	// Expected output trace:
+	assertLines(t, got, []string{
+		`"Clear"`,
+		`{"Append":{"id":0,"name":"Email/personal","description":"personal email"}}`,
+		`{"Append":{"id":1,"name":"Email/work","description":"work email"}}`,
+		`"Finished"`,
+		`{"Context":{"id":1,"options":[{"id":0,"name":"Copy username"},{"id":2,"name":"Open URL"}]}}`,
+		`"Close"`,
+		`"Finished"`,
+	})
+}
+
+func TestContextNoOptions(t *testing.T) {
+	got, _ := runConfig(t,
+		// Input trace:
+		[]string{
+			`{"Search":"q"}`,
+			`{"Context":0}`,
+			`"Exit"`,
+		},
+		Config{
+			OnSearch: func(ctx context.Context, q string, add func(SearchResult)) error {
+				add(SearchResult{Name: "entry", Description: "desc"})
+				return nil
+			},
+			OnActivate:        func(entry string) error { return nil },
+			OnContext:         func(entry string) ([]ContextOption, error) { return nil, nil },
+			OnActivateContext: func(entry string, optionID uint32) error { return nil },
+		},
+	)
+
+	// Expected output trace: an empty options list rather than null.
+	assertLines(t, got, []string{
+		`"Clear"`,
+		`{"Append":{"id":0,"name":"entry","description":"desc"}}`,
+		`"Finished"`,
+		`{"Context":{"id":0,"options":[]}}`,
+		`"Finished"`,
+	})
+}
+
+func TestActivateContextCallbackError(t *testing.T) {
+	got, logOut := runConfig(t,
+		// Input trace:
+		[]string{
+			`{"Search":"q"}`,
+			`{"ActivateContext":{"id":0,"context":0}}`,
+			`"Exit"`,
+		},
+		Config{
+			OnSearch: func(ctx context.Context, q string, add func(SearchResult)) error {
+				add(SearchResult{Name: "entry", Description: "desc"})
+				return nil
+			},
+			OnActivate: func(entry string) error { return nil },
+			OnContext:  func(entry string) ([]ContextOption, error) { return nil, nil },
+			OnActivateContext: func(entry string, optionID uint32) error {
+				return fmt.Errorf("xdg-open not found")
+			},
+		},
+	)
+
+	// "Close" is still sent even when OnActivateContext returns an error.
+	assertLines(t, got, []string{
+		`"Clear"`,
+		`{"Append":{"id":0,"name":"entry","description":"desc"}}`,
+		`"Finished"`,
+		`"Close"`,
+		`"Finished"`,
+	})
+
+	if !strings.Contains(logOut, "xdg-open not found") {
+		t.Errorf("log should contain callback error, got: %s", logOut)
+	}
+}
+
+// errorResult is the OnError callback of the error reporting tests.
+func errorResult(err error) SearchResult {
+	return SearchResult{Name: "Error: " + err.Error(), Description: "see the logs", IconName: "dialog-error"}
+}
+
+func TestSearchErrorResult(t *testing.T) {
+	got, logOut := runConfig(t,
+		// Input trace:
+		[]string{
+			`{"Search":"q"}`,
+			`{"Activate":1}`,
+			`{"Complete":1}`,
+			`{"Context":1}`,
+			`"Exit"`,
+		},
+		Config{
+			OnSearch: func(ctx context.Context, q string, add func(SearchResult)) error {
+				add(SearchResult{Name: "partial", Description: "desc"})
+				return fmt.Errorf("store locked")
+			},
+			OnActivate: func(entry string) error {
+				t.Error("OnActivate called on an error result")
+				return nil
+			},
+			OnComplete: func(query, entry string) string {
+				t.Error("OnComplete called on an error result")
+				return ""
+			},
+			OnContext: func(entry string) ([]ContextOption, error) {
+				t.Error("OnContext called on an error result")
+				return nil, nil
+			},
+			OnActivateContext: func(entry string, optionID uint32) error { return nil },
+			OnError:           errorResult,
+		},
+	)
+
+	// Expected output trace: the error comes last, and the launcher stays open
+	// when trying to use it.
+	assertLines(t, got, []string{
+		`"Clear"`,
+		`{"Append":{"id":0,"name":"partial","description":"desc"}}`,
+		`{"Append":{"id":1,"name":"Error: store locked","description":"see the logs","icon":{"Name":"dialog-error"}}}`,
+		`"Finished"`,
+		`"Finished"`,
+	})
+
+	if !strings.Contains(logOut, "store locked") {
+		t.Errorf("log should contain the search error, got: %s", logOut)
+	}
+}
+
+func TestActivateErrorReported(t *testing.T) {
+	got, _ := runConfig(t,
+		// Input trace: the launcher searches the filled query again.
+		[]string{
+			`{"Search":"gp q"}`,
+			`{"Activate":0}`,
+			`{"Search":"gp q"}`,
+			`{"Search":"gp qu"}`,
+			`"Exit"`,
+		},
+		Config{
+			OnSearch: func(ctx context.Context, q string, add func(SearchResult)) error {
+				add(SearchResult{Name: "entry", Description: "desc"})
+				return nil
+			},
+			OnActivate: func(entry string) error {
+				return fmt.Errorf("decryption failed")
+			},
+			OnError: errorResult,
+		},
+	)
+
+	// Expected output trace: no "Close", the error is shown once above the results.
+	assertLines(t, got, []string{
+		`"Clear"`,
+		`{"Append":{"id":0,"name":"entry","description":"desc"}}`,
+		`"Finished"`,
+		`{"Fill":"gp q"}`,
+		`"Clear"`,
+		`{"Append":{"id":0,"name":"Error: decryption failed","description":"see the logs","icon":{"Name":"dialog-error"}}}`,
+		`{"Append":{"id":1,"name":"entry","description":"desc"}}`,
+		`"Finished"`,
+		`"Clear"`,
+		`{"Append":{"id":0,"name":"entry","description":"desc"}}`,
+		`"Finished"`,
+		`"Finished"`,
+	})
+}
+
+func TestContextErrorReported(t *testing.T) {
+	got, _ := runConfig(t,
+		// Input trace:
+		[]string{
+			`{"Search":"q"}`,
+			`{"Context":0}`,
+			`"Exit"`,
+		},
+		Config{
+			OnSearch: func(ctx context.Context, q string, add func(SearchResult)) error {
+				add(SearchResult{Name: "entry", Description: "desc"})
+				return nil
+			},
+			OnActivate: func(entry string) error { return nil },
+			OnContext: func(entry string) ([]ContextOption, error) {
+				return nil, fmt.Errorf("gopass not found")
+			},
+			OnActivateContext: func(entry string, optionID uint32) error { return nil },
+			OnError:           errorResult,
+		},
+	)
+
+	assertLines(t, got, []string{
+		`"Clear"`,
+		`{"Append":{"id":0,"name":"entry","description":"desc"}}`,
+		`"Finished"`,
+		`{"Fill":"q"}`,
+		`"Finished"`,
+	})
+}
+
 func TestInvalidJSON(t *testing.T) {
 	got, logOut := runTrace(t,
 		// Input trace: malformed line then exit.
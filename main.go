@@ -2,25 +2,42 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"log/syslog"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
-	"syscall"
+	"sync/atomic"
 
 	"github.com/AnomalRoil/cosmic-gopass-plugin/autotype"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/config"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/fuzzy"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/history"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/index"
 	"github.com/AnomalRoil/cosmic-gopass-plugin/launcher"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/query"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/store"
 )
 
-var gopassPath string
+// cfg is the configuration of the plugin, loaded at startup.
+var cfg = config.Default()
 
-const maxResults = 19
+// usage is the history of the entries used, ranking the search results. It
+// is nil when the history could not be located.
+var usage *history.History
+
+// otpSearch records whether the last search was an "otp " query.
+var otpSearch atomic.Bool
 
 func findGopass() string {
+	if cfg.Gopass != "" {
+		return cfg.Gopass
+	}
 	if p, err := exec.LookPath("gopass"); err == nil {
 		return p
 	}
@@ -45,24 +62,278 @@ func findGopass() string {
 	return "gopass"
 }
 
-func loadEntries() map[string]string {
-	log.Println("Loading gopass entries...")
-	cmd := exec.Command(gopassPath, "--nosync", "ls", "-flat")
-	out, err := cmd.Output()
+// newBackend returns the configured store: the enabled sources searched
+// together, or else the configured backend, gopass when it is installed and
+// pass otherwise. gopass is the path of the gopass binary.
+func newBackend(gopass string) store.Store {
+	if len(cfg.Sources) > 0 {
+		var sources []store.Source
+		for _, src := range cfg.Sources {
+			if src.Disabled {
+				continue
+			}
+			sources = append(sources, store.Source{Name: src.Name, Store: newStore(src.Backend, &src.Store, gopass)})
+		}
+		return store.NewMulti(sources...)
+	}
+	backend := cfg.StoreBackend(func() bool {
+		_, err := exec.LookPath(gopass)
+		return err == nil
+	}, func() bool {
+		_, err := os.Stat(store.NewPass(cfg.PasswordStoreDir).Dir)
+		return err == nil
+	})
+	if backend == "pass" && cfg.Backend == "auto" {
+		log.Println("gopass was not found, using pass")
+	}
+	return newStore(backend, &cfg.Store, gopass)
+}
+
+// newStore returns the store of backend configured by c, running the gopass
+// binary at gopass for the gopass backend.
+func newStore(backend string, c *config.Store, gopass string) store.Store {
+	switch backend {
+	case "pass":
+		pass := store.NewPass(c.PasswordStoreDir)
+		log.Printf("Using the pass store in %s", pass.Dir)
+		return pass
+	case "keepassxc":
+		log.Printf("Using the KeePassXC database %s", c.KeePassXCDatabase)
+		k := store.NewKeePassXC(c.KeePassXCDatabase, c.KeePassXCKeyFile, c.KeePassXCPasswordCommand)
+		if c.KeePassXCRecycleBin != "" {
+			k.RecycleBin = c.KeePassXCRecycleBin
+		}
+		return k
+	}
+	return &store.Gopass{Path: gopass, Sync: cfg.Sync}
+}
+
+// loadHistory loads the usage history, starting over when it can't be read.
+func loadHistory() *history.History {
+	path, err := history.Path()
+	if err != nil {
+		log.Printf("ERROR: %v, usage history disabled", err)
+		return nil
+	}
+	h, err := history.Load(path)
 	if err != nil {
-		log.Printf("ERROR: gopass ls failed: %v", err)
+		log.Printf("ERROR: %v, starting a new usage history", err)
+	}
+	return h
+}
+
+// recordUse adds a use of entry to the usage history when err is nil, and
+// returns err.
+func recordUse(entry string, err error) error {
+	if err == nil && usage != nil {
+		if err := usage.Use(entry); err != nil {
+			log.Printf("ERROR: %v", err)
+		}
+	}
+	return err
+}
+
+// boost returns the score added to entry for having been used.
+func boost(entry string) int {
+	if usage == nil {
+		return 0
+	}
+	return usage.Boost(entry)
+}
+
+// searchKeys appends one result per key of entry of s starting with
+// keyPrefix, for the "gp entry:key" syntax.
+func searchKeys(s store.Store, entry, keyPrefix string, appendResult func(launcher.SearchResult)) error {
+	keys, keysErr := entryKeys(s, entry)
+	if keysErr != nil {
+		log.Printf("ERROR: listing keys of %s: %v", entry, keysErr)
+		// the key may still be copied if it exists
+		keys = []string{keyPrefix}
+	}
+	for _, key := range keys {
+		if key != "" && strings.HasPrefix(key, keyPrefix) {
+			appendResult(launcher.SearchResult{
+				Name:        entry + ":" + key,
+				Description: "Copy " + key + " to clipboard",
+				IconName:    cfg.Icon,
+			})
+		}
+	}
+	return keysErr
+}
+
+// folderIcon is the icon of the folder results.
+const folderIcon = "folder"
+
+// browseFolder appends the subfolders and then the entries directly in folder,
+// a lower case path ending with "/" or "/" alone for the root of the store,
+// so that the store can be browsed like a tree. Activating a subfolder fills
+// the launcher query with prefix and its path. browseFolder returns false when
+// there is no such folder.
+func browseFolder(entries map[string]string, prefix, folder string, describe func(string) string, appendResult func(launcher.SearchResult)) bool {
+	if folder == "/" {
+		folder = ""
+	}
+	// subfolders by lower case path, gopass being case sensitive but not the search
+	type subfolder struct {
+		name    string
+		entries int
+	}
+	subfolders := make(map[string]*subfolder)
+	var leaves []string
+	for lower, original := range entries {
+		rest, ok := strings.CutPrefix(lower, folder)
+		if !ok {
+			continue
+		}
+		i := strings.IndexByte(rest, '/')
+		if i < 0 {
+			leaves = append(leaves, original)
+			continue
+		}
+		path := lower[:len(folder)+i+1]
+		name := path
+		if len(original) == len(lower) {
+			name = original[:len(path)]
+		}
+		if sub, ok := subfolders[path]; ok {
+			// the name is picked consistently across searches when it differs in case
+			sub.name = min(sub.name, name)
+			sub.entries++
+		} else {
+			subfolders[path] = &subfolder{name, 1}
+		}
+	}
+	if len(subfolders) == 0 && len(leaves) == 0 {
+		return false
+	}
+	for _, path := range slices.Sorted(maps.Keys(subfolders)) {
+		sub := subfolders[path]
+		description := "1 entry"
+		if sub.entries > 1 {
+			description = fmt.Sprintf("%d entries", sub.entries)
+		}
+		appendResult(launcher.SearchResult{
+			Name:        sub.name,
+			Description: description,
+			IconName:    folderIcon,
+			Fill:        prefix + sub.name,
+		})
+	}
+	byLower := func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) }
+	slices.SortFunc(leaves, byLower)
+	for _, entry := range leaves {
+		appendResult(launcher.SearchResult{
+			Name:        entry,
+			Description: describe(entry),
+			IconName:    cfg.Icon,
+		})
+	}
+	return true
+}
+
+// completeEntry returns what the launcher query should be filled with when
+// completing entry: the path up to the next folder after what was already typed,
+// or the whole entry once there is no folder left to descend into.
+func completeEntry(query, entry string) string {
+	prefix := cfg.Prefix
+	if strings.HasPrefix(query, cfg.OTPPrefix) {
+		prefix = cfg.OTPPrefix
+	}
+	query = strings.TrimPrefix(query, prefix)
+	if len(query) <= len(entry) && strings.EqualFold(entry[:len(query)], query) {
+		if i := strings.IndexByte(entry[len(query):], '/'); i >= 0 {
+			return prefix + entry[:len(query)+i+1]
+		}
+	}
+	return prefix + entry
+}
+
+// search appends the results of the launcher query input, searching the
+// entries of s loaded by loader.
+func search(ctx context.Context, s store.Store, loader *entryLoader, input string, appendResult func(launcher.SearchResult)) error {
+	// a snapshot, updated entries are searched by the next search
+	allEntries, err := loader.entries()
+	if errors.Is(err, errLoading) {
+		// the entries are listed again after a failure, which is shown
+		// meanwhile
+		if err := loader.failure(); err != nil {
+			r := errorResult(err)
+			r.Disabled = true
+			appendResult(r)
+		}
+		appendResult(loadingResult)
 		return nil
 	}
-	allEntries := strings.Split(strings.TrimSpace(string(out)), "\n")
-	entries := make(map[string]string, len(allEntries))
-	for _, line := range allEntries {
-		if line != "" {
-			// we pre-compute the lower case version of the entry to avoid doing it in the loop
-			entries[strings.ToLower(line)] = line
+	// the same binary serves OTP queries, whose results copy OTP codes instead of passwords
+	isOTP := strings.HasPrefix(input, cfg.OTPPrefix)
+	otpSearch.Store(isOTP)
+	prefix := cfg.Prefix
+	describe := func(string) string { return cfg.Description }
+	if isOTP {
+		prefix = cfg.OTPPrefix
+		describe = otpDescription
+	}
+	if multi, ok := s.(*store.Multi); ok {
+		// federated results are labelled by their source
+		describeEntry := describe
+		describe = func(entry string) string { return "[" + multi.Source(entry) + "] " + describeEntry(entry) }
+	}
+
+	input = strings.TrimPrefix(input, prefix)
+	lowerQuery := strings.ToLower(input)
+	if i := strings.LastIndexByte(lowerQuery, ':'); i > 0 && !isOTP {
+		if original, ok := allEntries[lowerQuery[:i]]; ok {
+			return searchKeys(s, original, lowerQuery[i+1:], appendResult)
 		}
 	}
-	log.Printf("Loaded %d entries from gopass", len(entries))
-	return entries
+	// a query ending with a slash lists the content of that folder
+	if strings.HasSuffix(lowerQuery, "/") && browseFolder(allEntries, prefix, lowerQuery, describe, appendResult) {
+		return nil
+	}
+	// an exact match is displayed first when it exists
+	if exactMatch, ok := allEntries[lowerQuery]; ok {
+		appendResult(launcher.SearchResult{
+			Name:        exactMatch,
+			Description: describe(exactMatch),
+			IconName:    cfg.Icon,
+		})
+	}
+	q := query.Parse(lowerQuery)
+	var matches []fuzzy.Match
+	for lower, original := range allEntries {
+		if lower == lowerQuery {
+			// done just above
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// entries used often and recently come first, which
+		// also orders the results of an empty query
+		if score, ok := q.Match(lower); ok {
+			matches = append(matches, fuzzy.Match{Text: original, Score: score + boost(original)})
+		}
+	}
+	fuzzy.Sort(matches)
+	for _, m := range matches[:min(len(matches), cfg.MaxResults)] {
+		appendResult(launcher.SearchResult{
+			Name:        m.Text,
+			Description: describe(m.Text),
+			IconName:    cfg.Icon,
+		})
+	}
+	return nil
+}
+
+// onActivate runs the action of the search result name, an entry or an
+// "entry:key" of the entries of s loaded by loader.
+func onActivate(s store.Store, loader *entryLoader, name string) error {
+	if otpSearch.Load() {
+		return recordUse(name, copyOTP(s, name, autotype.Mode(cfg.PasteMode)))
+	}
+	entry, key := splitEntryKey(loader.index.Entries(), name)
+	return recordUse(entry, activate(s, entry, key))
 }
 
 func main() {
@@ -79,86 +350,50 @@ func main() {
 		defer syslogWriter.Close()
 	}
 
-	if args := os.Args; len(args) > 1 && args[1] == "paste" {
-		secret, err := io.ReadAll(os.Stdin)
-		if err != nil {
-			log.Printf("ERROR: reading secret from stdin: %v", err)
-			os.Exit(1)
-		}
-		if len(secret) == 0 {
-			log.Println("ERROR: stdin was empty, nothing to type")
-			os.Exit(1)
+	if args := os.Args; len(args) > 1 {
+		switch args[1] {
+		case "paste":
+			os.Exit(runPaste(args[2:]))
+		case "install":
+			os.Exit(runInstall(args[2:], os.Stdout, os.Stderr))
+		case "uninstall":
+			os.Exit(runUninstall(args[2:], os.Stdout, os.Stderr))
+		case "doctor":
+			os.Exit(runDoctor(args[2:], os.Stdout, os.Stderr))
 		}
-
-		if err := autotype.PressPaste(); err != nil {
-			log.Printf("ERROR: typeString failed: %v", err)
-		}
-		os.Exit(0)
 	}
 
-	gopassPath = findGopass()
-	log.Printf("Gopass plugin started as user=%s HOME=%s gopass=%s", os.Getenv("USER"), os.Getenv("HOME"), gopassPath)
+	if cfg, err = config.Load(); err != nil {
+		log.Printf("ERROR: %v, using the default configuration", err)
+	}
+	gopass := findGopass()
+	backend := newBackend(gopass)
+	log.Printf("Gopass plugin started as user=%s HOME=%s gopass=%s", os.Getenv("USER"), os.Getenv("HOME"), gopass)
 	defer log.Println("Gopass plugin stopped")
 
-	allEntries := loadEntries()
+	usage = loadHistory()
+	cachePath, err := index.CachePath()
+	if err != nil {
+		log.Printf("ERROR: %v, entries won't be cached", err)
+	}
+	entries := newEntryLoader(cachePath, func() ([]string, error) { return loadEntries(backend) })
+	entries.start(func() { watchStores(backend, entries) })
 
 	launcher.Run(launcher.Config{
 		Logger: log.Default(),
-		OnSearch: func(ctx context.Context, query string, appendResult func(launcher.SearchResult)) error {
-			query = strings.TrimPrefix(query, "gp ")
-			lowerQuery := strings.ToLower(query)
-			count := 0
-			// we're using a map to avoid always displaying the same entries in the same order when refining the search
-			// and to display an exact match first when it exists
-			if exactMatch, ok := allEntries[lowerQuery]; ok {
-				appendResult(launcher.SearchResult{
-					Name:        exactMatch,
-					Description: "Copy password to clipboard",
-					IconName:    "dialog-password",
-				})
-			}
-			for lower, original := range allEntries {
-				if lower == lowerQuery {
-					// done just above
-					continue
-				}
-				if ctx.Err() != nil {
-					return ctx.Err()
-				}
-				if lowerQuery == "" || strings.Contains(lower, lowerQuery) {
-					appendResult(launcher.SearchResult{
-						Name:        original,
-						Description: "Copy password to clipboard",
-						IconName:    "dialog-password",
-					})
-					count++
-					if count >= maxResults {
-						break
-					}
-				}
-			}
-			return nil
+		OnSearch: func(ctx context.Context, input string, appendResult func(launcher.SearchResult)) error {
+			return search(ctx, backend, entries, input, appendResult)
 		},
-		OnActivate: func(entry string) error {
-			cmd := exec.Command(gopassPath, "--nosync", "show", "-C=false", "-c=true", "-o", entry)
-			out, err := cmd.Output()
-			if err != nil {
-				return fmt.Errorf("gopass show -o failed: %w", err)
-			}
-			secret := strings.TrimSuffix(string(out), "\n")
-			log.Printf("Retrieved password for entry %s, spawning paste process", entry)
-			pasteCmd := exec.Command(os.Args[0], "paste")
-			pasteCmd.SysProcAttr = &syscall.SysProcAttr{
-				Setpgid: true,
-			}
-			pasteCmd.Stdin = strings.NewReader(secret)
-			pasteCmd.Stdout = nil
-			pasteCmd.Stderr = nil
-			if err := pasteCmd.Start(); err != nil {
-				return fmt.Errorf("'%s paste' start failed: %w", os.Args[0], err)
-			}
-			log.Printf("Started '%s paste' (pid %d) for entry %s", os.Args[0], pasteCmd.Process.Pid, entry)
-			return nil
+		OnActivate: func(name string) error {
+			return onActivate(backend, entries, name)
+		},
+		OnComplete: completeEntry,
+		OnContext: func(entry string) ([]launcher.ContextOption, error) {
+			return onContext(backend, entry)
+		},
+		OnActivateContext: func(entry string, optionID uint32) error {
+			return recordUse(entry, onActivateContext(backend, entry, optionID))
 		},
+		OnError: errorResult,
 	})
 }
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/autotype"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/config"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/launcher"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/store"
+)
+
+// spawned is a paste process started by the plugin.
+type spawned struct {
+	entry, secret string
+	flags         []string
+}
+
+// fakePlugin makes the plugin use the default configuration and a fake store
+// of secrets, recording the paste processes started instead of starting them.
+func fakePlugin(t *testing.T, secrets map[string]string) (*store.Fake, *entryLoader, *[]spawned) {
+	t.Helper()
+	fake := store.NewFake(secrets)
+	var started []spawned
+	oldCfg, oldSpawn := cfg, spawnPaste
+	t.Cleanup(func() {
+		cfg, spawnPaste = oldCfg, oldSpawn
+		keyCache.entry, keyCache.keys = "", nil
+		contextMenu.entry, contextMenu.actions = "", nil
+	})
+	cfg = config.Default()
+	spawnPaste = func(entry, secret string, flags ...string) error {
+		started = append(started, spawned{entry, secret, flags})
+		return nil
+	}
+	loader := newEntryLoader("", fake.List)
+	if err := loader.load(); err != nil {
+		t.Fatal(err)
+	}
+	return fake, loader, &started
+}
+
+// searchNames returns the names of the results of input in s.
+func searchNames(t *testing.T, s store.Store, loader *entryLoader, input string) []string {
+	t.Helper()
+	var names []string
+	err := search(context.Background(), s, loader, input, func(r launcher.SearchResult) {
+		names = append(names, r.Name)
+	})
+	if err != nil {
+		t.Fatalf("search(%q): %v", input, err)
+	}
+	return names
+}
+
+var secrets = map[string]string{
+	"websites/github.com/me":   "hunter2\nusername: me\nurl: https://github.com\ntotp: GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+	"websites/github.com/work": "correct horse\nusername: me@work",
+	"websites/gitlab.com":      "battery staple",
+	"email/perso":              "p4ss",
+	"git":                      "exact",
+}
+
+func TestBrowseFolder(t *testing.T) {
+	entries := make(map[string]string)
+	for _, e := range []string{
+		"websites/GitHub.com/me",
+		"websites/github.com/work",
+		"websites/example.org",
+		"websites/aws/root",
+		"email",
+		"websitesbackup",
+	} {
+		entries[strings.ToLower(e)] = e
+	}
+	describe := func(e string) string { return "copy " + e }
+
+	for folder, want := range map[string][]launcher.SearchResult{
+		"websites/": {
+			{Name: "websites/aws/", Description: "1 entry", IconName: folderIcon, Fill: "gp websites/aws/"},
+			{Name: "websites/GitHub.com/", Description: "2 entries", IconName: folderIcon, Fill: "gp websites/GitHub.com/"},
+			{Name: "websites/example.org", Description: "copy websites/example.org", IconName: cfg.Icon},
+		},
+		"websites/github.com/": {
+			{Name: "websites/GitHub.com/me", Description: "copy websites/GitHub.com/me", IconName: cfg.Icon},
+			{Name: "websites/github.com/work", Description: "copy websites/github.com/work", IconName: cfg.Icon},
+		},
+		"/": {
+			{Name: "websites/", Description: "4 entries", IconName: folderIcon, Fill: "gp websites/"},
+			{Name: "email", Description: "copy email", IconName: cfg.Icon},
+			{Name: "websitesbackup", Description: "copy websitesbackup", IconName: cfg.Icon},
+		},
+	} {
+		var got []launcher.SearchResult
+		if !browseFolder(entries, "gp ", folder, describe, func(r launcher.SearchResult) { got = append(got, r) }) {
+			t.Errorf("browseFolder(%q) found no folder", folder)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("browseFolder(%q) =\n%+v\nwant\n%+v", folder, got, want)
+		}
+	}
+
+	for _, folder := range []string{"web/", "email/", "websites/github.com/me/"} {
+		if browseFolder(entries, "gp ", folder, describe, func(launcher.SearchResult) {}) {
+			t.Errorf("browseFolder(%q) should find no folder", folder)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	fake, loader, _ := fakePlugin(t, secrets)
+	for input, want := range map[string][]string{
+		// the exact match comes first, then the best fuzzy matches
+		"gp git":                      {"git", "websites/gitlab.com", "websites/github.com/me", "websites/github.com/work"},
+		"gp ghme":                     {"websites/github.com/me"},
+		"gp web -work":                {"websites/gitlab.com", "websites/github.com/me"},
+		"gp websites/":                {"websites/github.com/", "websites/gitlab.com"},
+		"gp websites/gith":            {"websites/github.com/me", "websites/github.com/work"},
+		"gp websites/github.com/me:":  {"websites/github.com/me:username", "websites/github.com/me:url", "websites/github.com/me:totp"},
+		"gp websites/github.com/me:u": {"websites/github.com/me:username", "websites/github.com/me:url"},
+		"otp mail":                    {"email/perso"},
+		"gp nothing":                  nil,
+	} {
+		if got := searchNames(t, fake, loader, input); !slices.Equal(got, want) {
+			t.Errorf("search(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	cfg.MaxResults = 2
+	if got := searchNames(t, fake, loader, "gp "); len(got) != 2 {
+		t.Errorf("search returned %d results, want at most 2", len(got))
+	}
+}
+
+func TestFederatedSearch(t *testing.T) {
+	fakePlugin(t, nil)
+	work := store.NewFake(map[string]string{"websites/github.com": "correct horse", "vpn": "p4ss"})
+	multi := store.NewMulti(store.Source{Name: "personal", Store: store.NewFake(secrets)}, store.Source{Name: "work", Store: work})
+	loader := newEntryLoader("", func() ([]string, error) { return loadEntries(multi) })
+	if err := loader.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []launcher.SearchResult
+	search(context.Background(), multi, loader, "gp github", func(r launcher.SearchResult) { results = append(results, r) })
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+		if source, _, _ := strings.Cut(r.Name, "/"); r.Description != "["+source+"] "+cfg.Description {
+			t.Errorf("result %q has description %q", r.Name, r.Description)
+		}
+	}
+	want := []string{"work/websites/github.com", "personal/websites/github.com/me", "personal/websites/github.com/work"}
+	if !slices.Equal(names, want) {
+		t.Errorf("search = %q, want %q", names, want)
+	}
+	if got := searchNames(t, multi, loader, "gp @work git"); !slices.Equal(got, want[:1]) {
+		t.Errorf("search of a source = %q", got)
+	}
+
+	// the sources that can be listed are still searched
+	work.Fail(errors.New("store locked"))
+	if err := loader.load(); err != nil || len(loader.index.Entries()) != 5 {
+		t.Errorf("load with a failing source = %v, %d entries", err, len(loader.index.Entries()))
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	fake, loader, _ := fakePlugin(t, secrets)
+	fake.Fail(errors.New("store locked"))
+	if got := searchNames(t, fake, loader, "gp "); len(got) != 5 {
+		t.Errorf("the entries listed before a failure should still be searched, got %q", got)
+	}
+
+	loader = newEntryLoader("", fake.List)
+	if err := loader.load(); err == nil {
+		t.Fatal("load should fail while the store is locked")
+	}
+	fake.Fail(nil)
+	// the failure is shown while the entries are listed again
+	var results []launcher.SearchResult
+	if err := search(context.Background(), fake, loader, "gp ghme", func(r launcher.SearchResult) { results = append(results, r) }); err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	failed := errorResult(errors.New("store locked"))
+	failed.Disabled = true
+	if !reflect.DeepEqual(results, []launcher.SearchResult{failed, loadingResult}) {
+		t.Errorf("search after a failure = %+v, want the error and the loading result", results)
+	}
+	got := searchNames(t, fake, loader, "gp ghme")
+	for deadline := time.Now().Add(5 * time.Second); slices.Contains(got, loadingResult.Name) && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		got = searchNames(t, fake, loader, "gp ghme")
+	}
+	if !slices.Equal(got, []string{"websites/github.com/me"}) {
+		t.Errorf("search after the store recovered = %q", got)
+	}
+
+	release := make(chan struct{})
+	loader = newEntryLoader("", func() ([]string, error) { <-release; return fake.List() })
+	loader.start(func() {})
+	defer close(release)
+	results = nil
+	search(context.Background(), fake, loader, "gp git", func(r launcher.SearchResult) { results = append(results, r) })
+	if !reflect.DeepEqual(results, []launcher.SearchResult{loadingResult}) {
+		t.Errorf("search while loading = %+v, want the loading result", results)
+	}
+}
+
+func TestActivate(t *testing.T) {
+	fake, loader, started := fakePlugin(t, secrets)
+	mode := string(autotype.ModePasteKey)
+	for _, name := range []string{"websites/github.com/me", "websites/github.com/me:username"} {
+		searchNames(t, fake, loader, "gp "+name)
+		if err := onActivate(fake, loader, name); err != nil {
+			t.Fatalf("onActivate(%q): %v", name, err)
+		}
+	}
+	cfg.DefaultAction = "copy"
+	if err := onActivate(fake, loader, "email/perso"); err != nil {
+		t.Fatalf("onActivate: %v", err)
+	}
+	want := []spawned{
+		{"websites/github.com/me", "hunter2", []string{"-mode", mode, "-name", "websites/github.com/me"}},
+		{"websites/github.com/me", "me", []string{"-mode", mode, "-name", "username of websites/github.com/me"}},
+		{"email/perso", "p4ss", []string{"-copy-only", "-name", "email/perso"}},
+	}
+	if !reflect.DeepEqual(*started, want) {
+		t.Errorf("started %+v, want %+v", *started, want)
+	}
+
+	if err := onActivate(fake, loader, "websites/missing"); err == nil {
+		t.Error("activating a missing entry should fail")
+	}
+}
+
+func TestActivateOTP(t *testing.T) {
+	fake, loader, started := fakePlugin(t, secrets)
+	searchNames(t, fake, loader, "otp github.com/me")
+	if err := onActivate(fake, loader, "websites/github.com/me"); err != nil {
+		t.Fatalf("onActivate: %v", err)
+	}
+	if len(*started) != 1 || len((*started)[0].secret) != 6 || (*started)[0].flags[3] != "OTP code of websites/github.com/me" {
+		t.Errorf("started %+v, want an OTP code", *started)
+	}
+	if err := onActivate(fake, loader, "email/perso"); err == nil {
+		t.Error("activating an entry without OTP should fail")
+	}
+}
+
+func TestContextMenu(t *testing.T) {
+	fake, _, started := fakePlugin(t, secrets)
+	options, err := onContext(fake, "websites/github.com/me")
+	if err != nil {
+		t.Fatalf("onContext: %v", err)
+	}
+	var names []string
+	for _, o := range options {
+		names = append(names, o.Name)
+	}
+	want := []string{"Autotype", "Type password", "Copy username", "Copy url", "Copy totp", "Copy OTP", "Open URL"}
+	if !slices.Equal(names, want) {
+		t.Fatalf("context options %q, want %q", names, want)
+	}
+	if err := onActivateContext(fake, "websites/github.com/me", 2); err != nil {
+		t.Fatalf("onActivateContext: %v", err)
+	}
+	if len(*started) != 1 || (*started)[0].secret != "me" {
+		t.Errorf("started %+v, want the username copied", *started)
+	}
+	// the secret was decrypted once for the menu, and once to copy the key
+	if shown := fake.Shown(); len(shown) != 2 {
+		t.Errorf("decrypted %q", shown)
+	}
+	if err := onActivateContext(fake, "websites/github.com/me", 42); err == nil {
+		t.Error("activating an unknown option should fail")
+	}
+
+	options, err = onContext(fake, "websites/github.com/work")
+	if err != nil {
+		t.Fatalf("onContext: %v", err)
+	}
+	for _, o := range options {
+		if o.Name == "Copy OTP" || o.Name == "Open URL" {
+			t.Errorf("entry without OTP nor URL has option %q", o.Name)
+		}
+	}
+}
+
+func TestHOTPNotOffered(t *testing.T) {
+	fake, _, started := fakePlugin(t, map[string]string{"bank": "p4ss\notpauth://hotp/bank?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=3"})
+	options, err := onContext(fake, "bank")
+	if err != nil {
+		t.Fatalf("onContext: %v", err)
+	}
+	for _, o := range options {
+		if o.Name == "Copy OTP" {
+			t.Errorf("entry with an HOTP key has option %q", o.Name)
+		}
+	}
+	if err := copyOTP(fake, "bank", autotype.ModePasteKey); !errors.Is(err, store.ErrHOTP) {
+		t.Errorf("copyOTP = %v, want %v", err, store.ErrHOTP)
+	}
+	if len(*started) != 0 {
+		t.Errorf("started %+v for an HOTP key", *started)
+	}
+}
@@ -0,0 +1,106 @@
+// Package notify shows desktop notifications with the
+// org.freedesktop.Notifications service of the D-Bus session bus.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// AppName is the application name sent along with notifications.
+const AppName = "Gopass"
+
+// Urgency is the urgency level of a notification, Normal by default.
+type Urgency int
+
+const (
+	Normal Urgency = iota
+	Low
+	Critical
+)
+
+// level returns the urgency level sent to the notification server.
+func (u Urgency) level() byte {
+	switch u {
+	case Low:
+		return 0
+	case Critical:
+		return 2
+	}
+	return 1
+}
+
+// Notification is a desktop notification.
+type Notification struct {
+	Summary string
+	Body    string
+	// Icon is an icon name, such as "dialog-password".
+	Icon    string
+	Urgency Urgency
+	// ReplacesID is the ID of a previous notification to replace, if not zero.
+	ReplacesID uint32
+	// Timeout is how long the notification is shown, as decided by the
+	// notification server when zero.
+	Timeout time.Duration
+}
+
+// callTimeout bounds the time waiting for the reply of a method call.
+const callTimeout = 5 * time.Second
+
+// Conn is a connection to the session bus.
+type Conn struct {
+	conn *dbus.Conn
+}
+
+// Dial connects to the bus at address, in the D-Bus format such as
+// "unix:path=/run/user/1000/bus". An empty address is the session bus.
+func Dial(address string) (*Conn, error) {
+	var conn *dbus.Conn
+	var err error
+	if address == "" {
+		conn, err = dbus.ConnectSessionBus()
+	} else {
+		conn, err = dbus.Connect(address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the session bus: %w", err)
+	}
+	return &Conn{conn: conn}, nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Notify shows n and returns its ID, which can be used to replace it.
+func (c *Conn) Notify(n Notification) (uint32, error) {
+	timeout := int32(-1)
+	if n.Timeout > 0 {
+		timeout = int32(n.Timeout.Milliseconds())
+	}
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(n.Urgency.level())}
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	call := c.conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications").CallWithContext(ctx,
+		"org.freedesktop.Notifications.Notify", 0,
+		AppName, n.ReplacesID, n.Icon, n.Summary, n.Body, []string{}, hints, timeout)
+	var id uint32
+	if err := call.Store(&id); err != nil {
+		return 0, fmt.Errorf("notifying: %w", err)
+	}
+	return id, nil
+}
+
+// Send shows n using the session bus and returns its ID.
+func Send(n Notification) (uint32, error) {
+	c, err := Dial("")
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	return c.Notify(n)
+}
@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// busConfig is the configuration of a private session bus listening on the
+// socket in the directory replacing %s.
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s/bus</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startBus starts a private dbus-daemon and returns its address, skipping
+// the test when dbus-daemon isn't installed.
+func startBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+	dir := t.TempDir()
+	config := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(config, []byte(strings.Replace(busConfig, "%s", dir, 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(daemon, "--config-file", config, "--nofork", "--nopidfile")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	for range 100 {
+		if _, err := os.Stat(filepath.Join(dir, "bus")); err == nil {
+			return "unix:path=" + filepath.Join(dir, "bus")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("dbus-daemon didn't start")
+	return ""
+}
+
+// notification is a call of Notify received by the server.
+type notification struct {
+	appName             string
+	replacesID          uint32
+	icon, summary, body string
+	actions             []string
+	hints               map[string]dbus.Variant
+	expireTimeout       int32
+}
+
+// server is a notification server recording the notifications it receives.
+type server struct {
+	mu       sync.Mutex
+	received []notification
+}
+
+func (s *server) Notify(appName string, replacesID uint32, icon, summary, body string, actions []string, hints map[string]dbus.Variant, expireTimeout int32) (uint32, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = append(s.received, notification{appName, replacesID, icon, summary, body, actions, hints, expireTimeout})
+	return uint32(len(s.received)), nil
+}
+
+func (s *server) notifications() []notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.received
+}
+
+// startServer serves a notification server on the bus at address.
+func startServer(t *testing.T, address string) *server {
+	t.Helper()
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s := &server{}
+	if err := conn.Export(s, "/org/freedesktop/Notifications", "org.freedesktop.Notifications"); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := conn.RequestName("org.freedesktop.Notifications", dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("RequestName = %v, %v", reply, err)
+	}
+	return s
+}
+
+func TestNotify(t *testing.T) {
+	address := startBus(t)
+	s := startServer(t, address)
+	c, err := Dial(address)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	id, err := c.Notify(Notification{Summary: "Copied github.com/me", Body: "Clears in 45s", Icon: "dialog-password", Timeout: 3 * time.Second})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if id != 1 {
+		t.Errorf("id = %d, want 1", id)
+	}
+	if _, err := c.Notify(Notification{Summary: "Clipboard cleared", ReplacesID: id, Urgency: Low}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	ns := s.notifications()
+	if len(ns) != 2 {
+		t.Fatalf("got %d notifications, want 2", len(ns))
+	}
+	want := notification{AppName, 0, "dialog-password", "Copied github.com/me", "Clears in 45s", []string{},
+		map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(1))}, 3000}
+	if !reflect.DeepEqual(ns[0], want) {
+		t.Errorf("Notify arguments = %#v, want %#v", ns[0], want)
+	}
+	if got := ns[1]; got.replacesID != 1 || got.expireTimeout != -1 || got.hints["urgency"].Value() != byte(0) {
+		t.Errorf("replacing notification arguments = %#v", got)
+	}
+}
+
+func TestNotifyError(t *testing.T) {
+	c, err := Dial(startBus(t))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.Notify(Notification{Summary: "hi"}); err == nil || !strings.Contains(err.Error(), "notifying") {
+		t.Errorf("Notify error = %v, want the bus error", err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	address := startBus(t)
+	s := startServer(t, address)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", address)
+	if _, err := Send(Notification{Summary: "hi"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if n := len(s.notifications()); n != 1 {
+		t.Errorf("got %d notifications, want 1", n)
+	}
+}
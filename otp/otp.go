@@ -0,0 +1,168 @@
+// Package otp implements HOTP (RFC 4226) and TOTP (RFC 6238) one-time passwords
+// and extracts their parameters from gopass secrets.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/secret"
+)
+
+// Defaults used when an otpauth URI or a bare seed doesn't specify them.
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30
+)
+
+// Key holds the parameters of a one-time password generator.
+type Key struct {
+	// Type is either "totp" or "hotp".
+	Type      string
+	Seed      []byte
+	Algorithm string // SHA1, SHA256 or SHA512
+	Digits    int
+	Period    int    // in seconds, for TOTP
+	Counter   uint64 // for HOTP
+}
+
+// ParseURI parses an otpauth://totp/... or otpauth://hotp/... URI.
+func ParseURI(uri string) (*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, fmt.Errorf("invalid otpauth URI: %w", err)
+	}
+	if u.Scheme != "otpauth" {
+		return nil, fmt.Errorf("invalid otpauth URI scheme %q", u.Scheme)
+	}
+
+	q := u.Query()
+	k, err := FromSeed(q.Get("secret"))
+	if err != nil {
+		return nil, err
+	}
+
+	switch k.Type = strings.ToLower(u.Host); k.Type {
+	case "totp":
+	case "hotp":
+		if k.Counter, err = strconv.ParseUint(q.Get("counter"), 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid hotp counter %q", q.Get("counter"))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported otp type %q", u.Host)
+	}
+	if v := q.Get("algorithm"); v != "" {
+		k.Algorithm = strings.ToUpper(v)
+		if newHash(k.Algorithm) == nil {
+			return nil, fmt.Errorf("unsupported otp algorithm %q", v)
+		}
+	}
+	if v := q.Get("digits"); v != "" {
+		if k.Digits, err = strconv.Atoi(v); err != nil || k.Digits < 1 || k.Digits > 10 {
+			return nil, fmt.Errorf("invalid otp digits %q", v)
+		}
+	}
+	if v := q.Get("period"); v != "" {
+		if k.Period, err = strconv.Atoi(v); err != nil || k.Period < 1 {
+			return nil, fmt.Errorf("invalid otp period %q", v)
+		}
+	}
+	return k, nil
+}
+
+// FromSeed returns a TOTP key with default parameters for a base32 encoded seed.
+func FromSeed(seed string) (*Key, error) {
+	seed = strings.ToUpper(strings.Join(strings.Fields(seed), ""))
+	if seed == "" {
+		return nil, fmt.Errorf("empty otp seed")
+	}
+	raw, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(seed, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base32 otp seed: %w", err)
+	}
+	return &Key{
+		Type:      "totp",
+		Seed:      raw,
+		Algorithm: "SHA1",
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}, nil
+}
+
+// FromSecret finds the OTP parameters stored in a gopass secret, either as an
+// otpauth:// line or as a "totp:" key holding a seed or an otpauth URI.
+func FromSecret(s *secret.Secret) (*Key, error) {
+	if v, ok := s.Get("totp"); ok {
+		if strings.HasPrefix(v, "otpauth://") {
+			return ParseURI(v)
+		}
+		return FromSeed(v)
+	}
+	for _, line := range append([]string{s.Password}, strings.Split(s.Body, "\n")...) {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "otpauth://") {
+			return ParseURI(line)
+		}
+	}
+	return nil, fmt.Errorf("no otpauth URI or totp key found")
+}
+
+// Code returns the one-time password at time t. HOTP keys ignore t and use their counter.
+func (k *Key) Code(t time.Time) string {
+	counter := k.Counter
+	if k.Type != "hotp" {
+		counter = uint64(t.Unix()) / uint64(k.Period)
+	}
+	return HOTP(k.Seed, counter, k.Digits, k.Algorithm)
+}
+
+// Remaining returns how long the TOTP code at time t stays valid.
+func (k *Key) Remaining(t time.Time) time.Duration {
+	return Remaining(t, k.Period)
+}
+
+// Remaining returns how long a TOTP code of the given period stays valid at time t.
+func Remaining(t time.Time, period int) time.Duration {
+	p := time.Duration(period) * time.Second
+	return p - time.Duration(t.UnixNano())%p
+}
+
+// HOTP computes the RFC 4226 one-time password of seed for counter.
+func HOTP(seed []byte, counter uint64, digits int, algorithm string) string {
+	newH := newHash(algorithm)
+	if newH == nil {
+		newH = sha1.New
+	}
+	mac := hmac.New(newH, seed)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := uint64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+	mod := uint64(1)
+	for range digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+func newHash(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case "SHA1", "":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA512":
+		return sha512.New
+	}
+	return nil
+}
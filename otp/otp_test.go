@@ -0,0 +1,114 @@
+package otp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/secret"
+)
+
+func TestHOTPRFC4226(t *testing.T) {
+	seed := []byte("12345678901234567890")
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, w := range want {
+		if got := HOTP(seed, uint64(counter), 6, "SHA1"); got != w {
+			t.Errorf("HOTP(counter=%d) = %s, want %s", counter, got, w)
+		}
+	}
+}
+
+func TestTOTPRFC6238(t *testing.T) {
+	seeds := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	vectors := []struct {
+		unix int64
+		want map[string]string
+	}{
+		{59, map[string]string{"SHA1": "94287082", "SHA256": "46119246", "SHA512": "90693936"}},
+		{1111111109, map[string]string{"SHA1": "07081804", "SHA256": "68084774", "SHA512": "25091201"}},
+		{1234567890, map[string]string{"SHA1": "89005924", "SHA256": "91819424", "SHA512": "93441116"}},
+		{20000000000, map[string]string{"SHA1": "65353130", "SHA256": "77737706", "SHA512": "47863826"}},
+	}
+	for _, v := range vectors {
+		for alg, want := range v.want {
+			k := &Key{Type: "totp", Seed: []byte(seeds[alg]), Algorithm: alg, Digits: 8, Period: 30}
+			if got := k.Code(time.Unix(v.unix, 0)); got != want {
+				t.Errorf("%s at %d = %s, want %s", alg, v.unix, got, want)
+			}
+		}
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	seed := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	k, err := ParseURI("otpauth://totp/ACME:me@example.com?secret=" + seed + "&issuer=ACME&algorithm=sha256&digits=8&period=60")
+	if err != nil {
+		t.Fatalf("ParseURI: %v", err)
+	}
+	if k.Type != "totp" || k.Algorithm != "SHA256" || k.Digits != 8 || k.Period != 60 {
+		t.Errorf("unexpected key %+v", k)
+	}
+	if string(k.Seed) != "12345678901234567890" {
+		t.Errorf("Seed = %q", k.Seed)
+	}
+
+	k, err = ParseURI("otpauth://hotp/me?secret=" + seed + "&counter=3")
+	if err != nil {
+		t.Fatalf("ParseURI hotp: %v", err)
+	}
+	if got := k.Code(time.Now()); got != "969429" {
+		t.Errorf("hotp Code = %s, want 969429", got)
+	}
+}
+
+func TestParseURIErrors(t *testing.T) {
+	for _, uri := range []string{
+		"https://example.com/?secret=GEZDGNBV",
+		"otpauth://totp/me",
+		"otpauth://totp/me?secret=not*base32",
+		"otpauth://motp/me?secret=GEZDGNBV",
+		"otpauth://hotp/me?secret=GEZDGNBV",
+		"otpauth://totp/me?secret=GEZDGNBV&algorithm=MD5",
+		"otpauth://totp/me?secret=GEZDGNBV&digits=0",
+		"otpauth://totp/me?secret=GEZDGNBV&period=-30",
+	} {
+		if _, err := ParseURI(uri); err == nil {
+			t.Errorf("ParseURI(%q) should fail", uri)
+		}
+	}
+}
+
+func TestFromSecret(t *testing.T) {
+	for name, content := range map[string]string{
+		"otpauth line":  "pw\nuser: me\notpauth://totp/me?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n",
+		"totp seed key": "pw\ntotp: gezd gnbv gy3t qojq gezd gnbv gy3t qojq\n",
+		"totp uri key":  "pw\n---\ntotp: otpauth://totp/me?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n",
+	} {
+		k, err := FromSecret(secret.Parse([]byte(content)))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got := k.Code(time.Unix(59, 0)); got != "287082" {
+			t.Errorf("%s: Code = %s, want 287082", name, got)
+		}
+	}
+
+	if _, err := FromSecret(secret.Parse([]byte("pw\nuser: me\n"))); err == nil {
+		t.Error("FromSecret without otp should fail")
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	k := &Key{Type: "totp", Period: 30}
+	if got := k.Remaining(time.Unix(59, 0)); got != time.Second {
+		t.Errorf("Remaining at 59 = %v, want 1s", got)
+	}
+	if got := Remaining(time.Unix(60, 0), 30); got != 30*time.Second {
+		t.Errorf("Remaining at 60 = %v, want 30s", got)
+	}
+}
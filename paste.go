@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/autotype"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/clipboard"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/notify"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/otp"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/secret"
+)
+
+// pasteOptions are the flags of the paste subcommand.
+type pasteOptions struct {
+	mode       autotype.Mode
+	typer      string
+	sequence   string
+	clearAfter time.Duration
+	restore    bool
+	pasteOnce  bool
+	copyOnly   bool
+	// name describes the secret in notifications, which are only sent with notify.
+	name   string
+	notify bool
+}
+
+// defaultClearAfter is how long a copied secret stays in the clipboard, like in gopass.
+const defaultClearAfter = 45 * time.Second
+
+// restoreDelay leaves time to the focused application to fetch the pasted
+// secret before the previous clipboard content is restored.
+const restoreDelay = time.Second
+
+// sleep and after are replaced in tests to not wait for the clipboard to be
+// cleared, and sendNotification to not show notifications.
+var (
+	sleep            = time.Sleep
+	after            = time.After
+	sendNotification = notify.Send
+)
+
+func parsePasteArgs(args []string) (pasteOptions, error) {
+	fs := flag.NewFlagSet("paste", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	modeName := fs.String("mode", string(autotype.ModePasteKey), "how to paste the secret")
+	typer := fs.String("typer", "auto", "backend sending the key presses")
+	sequence := fs.String("sequence", "", "autotype sequence to type instead of pasting")
+	clearAfter := fs.Duration("clear-after", defaultClearAfter, "how long the secret stays in the clipboard")
+	restore := fs.Bool("restore", false, "restore the previous clipboard content after pasting")
+	pasteOnce := fs.Bool("paste-once", false, "remove the secret from the clipboard after it was pasted once")
+	copyOnly := fs.Bool("copy-only", false, "only copy the secret, without pasting it")
+	name := fs.String("name", "the secret", "what the secret is, for notifications")
+	notify := fs.Bool("notify", false, "show desktop notifications")
+	if err := fs.Parse(args); err != nil {
+		return pasteOptions{}, err
+	}
+	mode, err := autotype.ParseMode(*modeName)
+	if err != nil {
+		return pasteOptions{}, err
+	}
+	return pasteOptions{
+		mode:       mode,
+		typer:      *typer,
+		sequence:   *sequence,
+		clearAfter: *clearAfter,
+		restore:    *restore,
+		pasteOnce:  *pasteOnce,
+		copyOnly:   *copyOnly,
+		name:       *name,
+		notify:     *notify,
+	}, nil
+}
+
+// runPaste implements the paste subcommand: it reads the secret on stdin and
+// gets it into the focused window with the mode given by the -mode flag.
+// With -sequence, stdin holds the whole content of the entry and the sequence
+// is typed, unless the entry overrides it with an "autotype:" key.
+func runPaste(args []string) int {
+	opts, err := parsePasteArgs(args)
+	if err != nil {
+		log.Printf("ERROR: paste arguments: %v", err)
+		return 2
+	}
+
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		log.Printf("ERROR: reading secret from stdin: %v", err)
+		return 1
+	}
+	if len(input) == 0 {
+		log.Println("ERROR: stdin was empty, nothing to type")
+		return 1
+	}
+
+	keymap := autotype.USKeymap()
+	if opts.mode.UsesLayout() || opts.sequence != "" {
+		if keymap, err = autotype.LoadKeymap(); err != nil {
+			log.Printf("WARNING: %v, assuming a US layout", err)
+		}
+	}
+	var cb clipboard.Clipboard
+	if opts.sequence == "" && opts.mode.UsesClipboard() {
+		if cb, err = clipboard.Detect(); err != nil {
+			log.Printf("ERROR: %v", err)
+			notifyUser(opts, 0, "Copying "+opts.name+" failed", err.Error(), notify.Critical)
+			return 1
+		}
+	}
+	var typer autotype.Typer
+	if !opts.copyOnly {
+		if typer, err = autotype.New(opts.typer, keymap); err != nil {
+			log.Printf("ERROR: %v", err)
+			// the secret is still copied, so that it can be pasted by hand
+		}
+	}
+
+	c, err := paste(opts, input, typer, cb)
+	if typer != nil {
+		typer.Close()
+	}
+	if err != nil {
+		log.Printf("ERROR: paste failed: %v", err)
+		notifyUser(opts, c.notification, "Pasting "+opts.name+" failed", err.Error(), notify.Critical)
+	}
+	if cb != nil {
+		if err := settleClipboard(opts, cb, c); err != nil {
+			log.Printf("ERROR: %v", err)
+			notifyUser(opts, c.notification, "Clearing the clipboard failed", err.Error(), notify.Critical)
+		}
+	}
+	return 0
+}
+
+// notifyUser shows a notification when enabled by opts, replacing the one
+// with the ID replaces if not zero, and returns its ID.
+func notifyUser(opts pasteOptions, replaces uint32, summary, body string, urgency notify.Urgency) uint32 {
+	if !opts.notify {
+		return 0
+	}
+	id, err := sendNotification(notify.Notification{
+		Summary:    summary,
+		Body:       body,
+		Icon:       "dialog-password",
+		Urgency:    urgency,
+		ReplacesID: replaces,
+	})
+	if err != nil {
+		log.Printf("WARNING: %v", err)
+	}
+	return id
+}
+
+// copied is what paste left in the clipboard, for settleClipboard to clean up.
+type copied struct {
+	secret   []byte
+	previous []byte
+	// previousType is the MIME type of previous, empty for text.
+	previousType string
+	// offer is set when the secret can only be pasted once.
+	offer *clipboard.Offer
+	// pasted is set once the paste key was pressed, after which the
+	// previous content can be restored right away.
+	pasted bool
+	// notification is the ID of the notification announcing the copy.
+	notification uint32
+}
+
+// paste gets input into the focused window with typer, as asked by opts.
+// Unless the mode types it, input is first copied to cb, along with the
+// previous content of cb when it must be restored. The typer can be nil if
+// none works, then input is only copied like with opts.copyOnly.
+func paste(opts pasteOptions, input []byte, typer autotype.Typer, cb clipboard.Clipboard) (copied, error) {
+	if typer == nil && (opts.sequence != "" || !opts.mode.UsesClipboard()) {
+		return copied{}, fmt.Errorf("no typer available")
+	}
+	if opts.sequence != "" {
+		return copied{}, runSequence(typer, opts.sequence, secret.Parse(input))
+	}
+	if !opts.mode.UsesClipboard() {
+		return copied{}, autotype.Paste(typer, opts.mode, string(input))
+	}
+
+	c := copied{secret: input}
+	if opts.restore {
+		var err error
+		if typed, ok := cb.(clipboard.TypedClipboard); ok {
+			c.previous, c.previousType, err = typed.PasteTyped()
+		} else {
+			c.previous, err = cb.Paste()
+		}
+		if err != nil {
+			log.Printf("WARNING: not restoring the clipboard, reading it failed: %v", err)
+			c.previous = nil
+		}
+	}
+	if once, ok := cb.(clipboard.OnceCopier); ok && opts.pasteOnce {
+		offer, err := once.CopyOnce(input, true)
+		if err != nil {
+			return copied{}, fmt.Errorf("copying to the clipboard: %w", err)
+		}
+		c.offer = offer
+	} else {
+		if opts.pasteOnce {
+			log.Println("WARNING: the clipboard can't serve a secret only once, it will be cleared after a delay")
+		}
+		if err := cb.Copy(input, true); err != nil {
+			return copied{}, fmt.Errorf("copying to the clipboard: %w", err)
+		}
+	}
+	c.notification = notifyUser(opts, 0, "Copied "+opts.name, copiedBody(opts, c), notify.Normal)
+	if opts.copyOnly {
+		return c, nil
+	}
+	if typer == nil {
+		return c, fmt.Errorf("no typer available, the secret was only copied")
+	}
+	err := autotype.Paste(typer, opts.mode, string(input))
+	c.pasted = err == nil
+	return c, err
+}
+
+// settleClipboard removes the secret from the clipboard. A secret served once
+// is revoked if it wasn't pasted within opts.clearAfter. Otherwise the previous
+// content is restored shortly after the paste key was pressed when asked to.
+// A secret left to be pasted by hand is cleared, or replaced by the previous
+// content, once opts.clearAfter elapsed, provided nothing else was copied
+// meanwhile.
+func settleClipboard(opts pasteOptions, cb clipboard.Clipboard, c copied) error {
+	if c.offer != nil {
+		select {
+		case <-c.offer.Done():
+			log.Println("The secret was pasted once and removed from the clipboard")
+			notifyUser(opts, c.notification, "Pasted "+opts.name, "It was removed from the clipboard", notify.Low)
+		case <-after(opts.clearAfter):
+			if err := c.offer.Revoke(); err != nil {
+				return fmt.Errorf("revoking the clipboard: %w", err)
+			}
+			log.Println("The secret wasn't pasted, removed it from the clipboard")
+			notifyUser(opts, c.notification, "Clipboard cleared", opts.name+" was removed from the clipboard", notify.Low)
+		}
+		if c.previous != nil {
+			return restoreClipboard(cb, c)
+		}
+		return nil
+	}
+
+	if c.pasted && c.previous != nil {
+		sleep(restoreDelay)
+		return restoreClipboard(cb, c)
+	}
+	wait := opts.clearAfter
+	if c.pasted && opts.restore {
+		// the clipboard was empty before, so restoring it means clearing it
+		wait = restoreDelay
+	}
+	sleep(wait)
+	if c.previous != nil {
+		if current, err := cb.Paste(); err != nil || !bytes.Equal(current, c.secret) {
+			// something else was copied meanwhile
+			return nil
+		}
+		if err := restoreClipboard(cb, c); err != nil {
+			return err
+		}
+	} else if err := clipboard.ClearIf(cb, c.secret); err != nil {
+		if errors.Is(err, clipboard.ErrNotOurs) {
+			return nil
+		}
+		return fmt.Errorf("clearing the clipboard: %w", err)
+	} else {
+		log.Println("Cleared the clipboard")
+	}
+	if !c.pasted || !opts.restore {
+		notifyUser(opts, c.notification, "Clipboard cleared", opts.name+" was removed from the clipboard", notify.Low)
+	}
+	return nil
+}
+
+// copiedBody tells how long the secret copied by c stays in the clipboard.
+func copiedBody(opts pasteOptions, c copied) string {
+	switch {
+	case c.offer != nil:
+		return fmt.Sprintf("Removed from the clipboard once pasted, or in %s", opts.clearAfter)
+	case opts.restore && opts.copyOnly:
+		return fmt.Sprintf("The previous clipboard content is restored in %s", opts.clearAfter)
+	case opts.restore:
+		return "The previous clipboard content is restored after pasting"
+	}
+	return fmt.Sprintf("Clears in %s", opts.clearAfter)
+}
+
+// restoreClipboard puts back the previous content of cb saved in c, in its
+// original MIME type so that images and other content aren't turned into text.
+func restoreClipboard(cb clipboard.Clipboard, c copied) error {
+	var err error
+	if typed, ok := cb.(clipboard.TypedClipboard); ok && c.previousType != "" {
+		err = typed.CopyTyped(c.previous, c.previousType)
+	} else {
+		err = cb.Copy(c.previous, false)
+	}
+	if err != nil {
+		return fmt.Errorf("restoring the clipboard: %w", err)
+	}
+	log.Println("Restored the previous clipboard content")
+	return nil
+}
+
+// runSequence types the autotype sequence of sec, or sequence if it has none.
+func runSequence(typer autotype.Typer, sequence string, sec *secret.Secret) error {
+	if v, ok := sec.Get("autotype"); ok && v != "" {
+		sequence = v
+	}
+	steps, err := autotype.ParseSequence(sequence)
+	if err != nil {
+		return err
+	}
+	return autotype.RunSequence(typer, steps, secretField(sec))
+}
+
+// secretField returns a lookup function for the fields of sec used in autotype sequences.
+func secretField(sec *secret.Secret) func(field string) (string, error) {
+	return func(field string) (string, error) {
+		switch field {
+		case "password":
+			return sec.Password, nil
+		case "totp":
+			key, err := otp.FromSecret(sec)
+			if err != nil {
+				return "", err
+			}
+			return key.Code(time.Now()), nil
+		case "username":
+			for _, k := range []string{"username", "user", "login"} {
+				if v, ok := sec.Get(k); ok {
+					return v, nil
+				}
+			}
+			return "", fmt.Errorf("no username, user or login key in the secret")
+		}
+		if v, ok := sec.Get(field); ok {
+			return v, nil
+		}
+		return "", fmt.Errorf("no %s key in the secret", field)
+	}
+}
+
+// pasteFlags returns the flags of the paste process common to all actions,
+// from the configuration.
+func pasteFlags() []string {
+	return []string{
+		"-typer", cfg.Typer,
+		"-clear-after", cfg.ClearAfter.String(),
+		fmt.Sprintf("-restore=%t", cfg.RestoreClipboard),
+		fmt.Sprintf("-paste-once=%t", cfg.PasteOnce),
+		fmt.Sprintf("-notify=%t", cfg.Notify),
+	}
+}
+
+// modeFlags returns the flags of the paste process pasting with mode, or only
+// copying when mode is empty.
+func modeFlags(mode autotype.Mode) []string {
+	if mode == "" {
+		return []string{"-copy-only"}
+	}
+	return []string{"-mode", string(mode)}
+}
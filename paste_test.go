@@ -0,0 +1,359 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/autotype"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/clipboard"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/config"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/notify"
+)
+
+func TestParsePasteArgs(t *testing.T) {
+	opts, err := parsePasteArgs(nil)
+	if err != nil {
+		t.Fatalf("parsePasteArgs: %v", err)
+	}
+	if want := (pasteOptions{mode: autotype.ModePasteKey, typer: "auto", clearAfter: defaultClearAfter, name: "the secret"}); opts != want {
+		t.Errorf("default options = %+v, want %+v", opts, want)
+	}
+
+	opts, err = parsePasteArgs([]string{"-typer", "wtype", "-mode", "type", "-sequence", "{PASSWORD}", "-clear-after", "10s", "-restore", "-paste-once", "-name", "a/b", "-notify"})
+	if err != nil {
+		t.Fatalf("parsePasteArgs: %v", err)
+	}
+	if want := (pasteOptions{mode: autotype.ModeType, typer: "wtype", sequence: "{PASSWORD}", clearAfter: 10 * time.Second, restore: true, pasteOnce: true, name: "a/b", notify: true}); opts != want {
+		t.Errorf("options = %+v, want %+v", opts, want)
+	}
+
+	for _, args := range [][]string{{"-mode", "telepathy"}, {"-nope"}, {"-clear-after", "soon"}} {
+		if _, err := parsePasteArgs(args); err == nil {
+			t.Errorf("parsePasteArgs(%q) should fail", args)
+		}
+	}
+}
+
+func TestPasteModes(t *testing.T) {
+	for mode, want := range map[autotype.Mode][]string{
+		autotype.ModePasteKey:    {"press XF86Paste"},
+		autotype.ModeCtrlV:       {"press ctrl+v"},
+		autotype.ModeShiftInsert: {"press shift+Insert"},
+		autotype.ModeType:        {"type hunter2"},
+	} {
+		var f autotype.Fake
+		var cb clipboard.Fake
+		if _, err := paste(pasteOptions{mode: mode}, []byte("hunter2"), &f, &cb); err != nil {
+			t.Errorf("paste(%s): %v", mode, err)
+		}
+		if !reflect.DeepEqual(f.Events, want) {
+			t.Errorf("paste(%s) events = %q, want %q", mode, f.Events, want)
+		}
+
+		got, _ := cb.Paste()
+		if mode.UsesClipboard() && (string(got) != "hunter2" || !cb.Sensitive()) {
+			t.Errorf("paste(%s) clipboard = %q, sensitive %v, want the secret", mode, got, cb.Sensitive())
+		}
+		if !mode.UsesClipboard() && got != nil {
+			t.Errorf("paste(%s) clipboard = %q, want it untouched", mode, got)
+		}
+	}
+}
+
+func TestPasteSequence(t *testing.T) {
+	opts := pasteOptions{mode: autotype.ModePasteKey, sequence: autotype.DefaultSequence}
+
+	var f autotype.Fake
+	if _, err := paste(opts, []byte("hunter2\nlogin: me\n"), &f, nil); err != nil {
+		t.Fatalf("paste: %v", err)
+	}
+	if want := []string{"type me", "press Tab", "type hunter2", "press Return"}; !reflect.DeepEqual(f.Events, want) {
+		t.Errorf("events = %q, want %q", f.Events, want)
+	}
+
+	// the autotype key of the entry overrides the default sequence
+	f = autotype.Fake{}
+	if _, err := paste(opts, []byte("hunter2\nemail: me@example.com\nautotype: \"{S:email}{ENTER}\"\n"), &f, nil); err != nil {
+		t.Fatalf("paste: %v", err)
+	}
+	if want := []string{"type me@example.com", "press Return"}; !reflect.DeepEqual(f.Events, want) {
+		t.Errorf("events = %q, want %q", f.Events, want)
+	}
+
+	// nothing is typed when a field is missing
+	f = autotype.Fake{}
+	if _, err := paste(opts, []byte("hunter2\n"), &f, nil); err == nil {
+		t.Error("paste should fail without username")
+	}
+	if len(f.Events) != 0 {
+		t.Errorf("events = %q, want none", f.Events)
+	}
+}
+
+func TestPasteTyperError(t *testing.T) {
+	f := autotype.Fake{Err: errors.New("device busy")}
+	var cb clipboard.Fake
+	if _, err := paste(pasteOptions{mode: autotype.ModeCtrlV}, []byte("hunter2"), &f, &cb); err == nil {
+		t.Error("paste should report typer errors")
+	}
+	if got, _ := cb.Paste(); string(got) != "hunter2" {
+		t.Errorf("clipboard = %q, the secret should still be copied", got)
+	}
+
+	if _, err := paste(pasteOptions{mode: autotype.ModeType}, []byte("hunter2"), nil, &cb); err == nil {
+		t.Error("paste should fail to type without typer")
+	}
+}
+
+func TestClipboardClearedAfterTimeout(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	opts := pasteOptions{mode: autotype.ModePasteKey, clearAfter: 30 * time.Second}
+	var cb clipboard.Fake
+	cb.Copy([]byte("previous"), false)
+
+	c, err := paste(opts, []byte("hunter2"), &autotype.Fake{}, &cb)
+	if err != nil {
+		t.Fatalf("paste: %v", err)
+	}
+	if c.previous != nil {
+		t.Errorf("previous = %q, should not be read without restore", c.previous)
+	}
+	if err := settleClipboard(opts, &cb, c); err != nil {
+		t.Fatalf("settleClipboard: %v", err)
+	}
+	if got, _ := cb.Paste(); got != nil {
+		t.Errorf("clipboard = %q, want it cleared", got)
+	}
+	if want := []time.Duration{30 * time.Second}; !reflect.DeepEqual(slept, want) {
+		t.Errorf("slept %v, want %v", slept, want)
+	}
+
+	// something copied meanwhile is left alone
+	c, _ = paste(opts, []byte("hunter2"), &autotype.Fake{}, &cb)
+	cb.Copy([]byte("newer"), false)
+	if err := settleClipboard(opts, &cb, c); err != nil {
+		t.Fatalf("settleClipboard: %v", err)
+	}
+	if got, _ := cb.Paste(); string(got) != "newer" {
+		t.Errorf("clipboard = %q, want %q", got, "newer")
+	}
+}
+
+func TestClipboardRestored(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	opts := pasteOptions{mode: autotype.ModeCtrlV, clearAfter: 30 * time.Second, restore: true}
+	var cb clipboard.Fake
+	cb.CopyTyped([]byte("\x89PNG"), "image/png")
+
+	c, err := paste(opts, []byte("hunter2"), &autotype.Fake{}, &cb)
+	if err != nil {
+		t.Fatalf("paste: %v", err)
+	}
+	if got, _ := cb.Paste(); string(got) != "hunter2" {
+		t.Errorf("clipboard = %q while pasting, want the secret", got)
+	}
+	if err := settleClipboard(opts, &cb, c); err != nil {
+		t.Fatalf("settleClipboard: %v", err)
+	}
+	if got, mimeType, _ := cb.PasteTyped(); string(got) != "\x89PNG" || mimeType != "image/png" || cb.Sensitive() {
+		t.Errorf("clipboard = %q of type %q, want the previous image restored", got, mimeType)
+	}
+	if want := []time.Duration{restoreDelay}; !reflect.DeepEqual(slept, want) {
+		t.Errorf("slept %v, want %v", slept, want)
+	}
+}
+
+func TestClipboardPasteOnce(t *testing.T) {
+	timeout := make(chan time.Time)
+	after = func(time.Duration) <-chan time.Time { return timeout }
+	defer func() { after = time.After }()
+
+	opts := pasteOptions{mode: autotype.ModePasteKey, clearAfter: 30 * time.Second, pasteOnce: true, restore: true}
+	var cb clipboard.Fake
+	cb.Copy([]byte("previous"), false)
+
+	c, err := paste(opts, []byte("hunter2"), &autotype.Fake{}, &cb)
+	if err != nil {
+		t.Fatalf("paste: %v", err)
+	}
+	if c.offer == nil {
+		t.Fatal("the secret should be served once")
+	}
+	// the application reads the clipboard once
+	if got, _ := cb.Paste(); string(got) != "hunter2" {
+		t.Errorf("first paste = %q, want the secret", got)
+	}
+	if err := settleClipboard(opts, &cb, c); err != nil {
+		t.Fatalf("settleClipboard: %v", err)
+	}
+	if got, _ := cb.Paste(); string(got) != "previous" {
+		t.Errorf("clipboard = %q, want the previous content restored", got)
+	}
+}
+
+func TestClipboardPasteOnceRevokedOnTimeout(t *testing.T) {
+	timeout := make(chan time.Time, 1)
+	timeout <- time.Now()
+	after = func(time.Duration) <-chan time.Time { return timeout }
+	defer func() { after = time.After }()
+
+	opts := pasteOptions{mode: autotype.ModePasteKey, clearAfter: 30 * time.Second, pasteOnce: true}
+	var cb clipboard.Fake
+
+	c, err := paste(opts, []byte("hunter2"), &autotype.Fake{}, &cb)
+	if err != nil {
+		t.Fatalf("paste: %v", err)
+	}
+	if err := settleClipboard(opts, &cb, c); err != nil {
+		t.Fatalf("settleClipboard: %v", err)
+	}
+	if got, _ := cb.Paste(); got != nil {
+		t.Errorf("clipboard = %q, want the secret revoked", got)
+	}
+}
+
+func TestPasteCopyOnly(t *testing.T) {
+	opts, err := parsePasteArgs([]string{"-copy-only"})
+	if err != nil {
+		t.Fatalf("parsePasteArgs: %v", err)
+	}
+	var cb clipboard.Fake
+	if _, err := paste(opts, []byte("hunter2"), nil, &cb); err != nil {
+		t.Fatalf("paste: %v", err)
+	}
+	if got, _ := cb.Paste(); string(got) != "hunter2" {
+		t.Errorf("clipboard = %q, want the secret", got)
+	}
+}
+
+func TestClipboardRestoredAfterCopyOnly(t *testing.T) {
+	defer func() { sleep = time.Sleep }()
+	opts := pasteOptions{copyOnly: true, clearAfter: 30 * time.Second, restore: true}
+	var cb clipboard.Fake
+	cb.Copy([]byte("previous"), false)
+	c, err := paste(opts, []byte("hunter2"), nil, &cb)
+	if err != nil {
+		t.Fatalf("paste: %v", err)
+	}
+	// the secret stays until clear_after, to be pasted by hand
+	var slept []time.Duration
+	sleep = func(d time.Duration) {
+		slept = append(slept, d)
+		if got, _ := cb.Paste(); string(got) != "hunter2" {
+			t.Errorf("clipboard = %q while waiting, want the secret", got)
+		}
+	}
+	if err := settleClipboard(opts, &cb, c); err != nil {
+		t.Fatalf("settleClipboard: %v", err)
+	}
+	if got, _ := cb.Paste(); string(got) != "previous" {
+		t.Errorf("clipboard = %q, want the previous content restored", got)
+	}
+	if want := []time.Duration{opts.clearAfter}; !reflect.DeepEqual(slept, want) {
+		t.Errorf("slept %v, want %v", slept, want)
+	}
+
+	// nor is what was copied meanwhile replaced
+	c, _ = paste(opts, []byte("hunter2"), nil, &cb)
+	sleep = func(time.Duration) { cb.Copy([]byte("newer"), false) }
+	if err := settleClipboard(opts, &cb, c); err != nil {
+		t.Fatalf("settleClipboard: %v", err)
+	}
+	if got, _ := cb.Paste(); string(got) != "newer" {
+		t.Errorf("clipboard = %q, want what was copied meanwhile", got)
+	}
+}
+
+func TestPasteFlagsFromConfig(t *testing.T) {
+	defer func() { cfg = config.Default() }()
+	cfg = config.Default()
+	cfg.Typer = "wtype"
+	cfg.ClearAfter = config.Duration(10 * time.Second)
+	cfg.PasteOnce = true
+
+	opts, err := parsePasteArgs(append(pasteFlags(), modeFlags(autotype.ModeShiftInsert)...))
+	if err != nil {
+		t.Fatalf("parsePasteArgs: %v", err)
+	}
+	want := pasteOptions{mode: autotype.ModeShiftInsert, typer: "wtype", clearAfter: 10 * time.Second, pasteOnce: true, name: "the secret", notify: true}
+	if opts != want {
+		t.Errorf("options = %+v, want %+v", opts, want)
+	}
+}
+
+// recordNotifications replaces sendNotification to record the notifications
+// until the test ends.
+func recordNotifications(t *testing.T) *[]notify.Notification {
+	var sent []notify.Notification
+	sendNotification = func(n notify.Notification) (uint32, error) {
+		sent = append(sent, n)
+		return uint32(len(sent)), nil
+	}
+	t.Cleanup(func() { sendNotification = notify.Send })
+	return &sent
+}
+
+func TestPasteNotifications(t *testing.T) {
+	sent := recordNotifications(t)
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	opts := pasteOptions{mode: autotype.ModePasteKey, clearAfter: 45 * time.Second, name: "github.com/me", notify: true}
+	var cb clipboard.Fake
+	c, err := paste(opts, []byte("hunter2"), &autotype.Fake{}, &cb)
+	if err != nil {
+		t.Fatalf("paste: %v", err)
+	}
+	if err := settleClipboard(opts, &cb, c); err != nil {
+		t.Fatalf("settleClipboard: %v", err)
+	}
+	want := []notify.Notification{
+		{Summary: "Copied github.com/me", Body: "Clears in 45s", Icon: "dialog-password"},
+		{Summary: "Clipboard cleared", Body: "github.com/me was removed from the clipboard", Icon: "dialog-password", Urgency: notify.Low, ReplacesID: 1},
+	}
+	if !reflect.DeepEqual(*sent, want) {
+		t.Errorf("notifications = %+v, want %+v", *sent, want)
+	}
+
+	// without notify, nothing is sent
+	*sent = nil
+	opts.notify = false
+	c, _ = paste(opts, []byte("hunter2"), &autotype.Fake{}, &cb)
+	settleClipboard(opts, &cb, c)
+	if len(*sent) != 0 {
+		t.Errorf("notifications sent while disabled: %+v", *sent)
+	}
+}
+
+func TestPasteOnceNotifications(t *testing.T) {
+	sent := recordNotifications(t)
+	timeout := make(chan time.Time)
+	after = func(time.Duration) <-chan time.Time { return timeout }
+	defer func() { after = time.After }()
+
+	opts := pasteOptions{mode: autotype.ModePasteKey, clearAfter: 10 * time.Second, pasteOnce: true, name: "github.com/me", notify: true}
+	var cb clipboard.Fake
+	c, err := paste(opts, []byte("hunter2"), &autotype.Fake{}, &cb)
+	if err != nil {
+		t.Fatalf("paste: %v", err)
+	}
+	cb.Paste()
+	if err := settleClipboard(opts, &cb, c); err != nil {
+		t.Fatalf("settleClipboard: %v", err)
+	}
+	want := []notify.Notification{
+		{Summary: "Copied github.com/me", Body: "Removed from the clipboard once pasted, or in 10s", Icon: "dialog-password"},
+		{Summary: "Pasted github.com/me", Body: "It was removed from the clipboard", Icon: "dialog-password", Urgency: notify.Low, ReplacesID: 1},
+	}
+	if !reflect.DeepEqual(*sent, want) {
+		t.Errorf("notifications = %+v, want %+v", *sent, want)
+	}
+}
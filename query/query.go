@@ -0,0 +1,154 @@
+// Package query parses the search queries typed in the launcher.
+//
+// A query is a list of space separated terms, all of which must match an
+// entry:
+//
+//	work aws      entries fuzzily matching both "work" and "aws"
+//	-staging      entries not containing "staging"
+//	^websites/    entries starting with "websites/"
+//	prod$         entries ending with "prod"
+//	store:work    entries of the "work" store, mounted as "work/"
+//	@work         the same, like the sources of a federated search
+//	dir:infra/    entries in an "infra" folder, at any depth
+//
+// Negation applies to any term, such as "-dir:old" or "-^archive/".
+package query
+
+import (
+	"strings"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/fuzzy"
+)
+
+// Field is what a term matches.
+type Field int
+
+const (
+	// Path terms match the path of the entry.
+	Path Field = iota
+	// Store terms match the store of the entry, its first folder.
+	Store
+	// Dir terms match the folders containing the entry.
+	Dir
+)
+
+// fields are the prefixes of filter terms.
+var fields = map[string]Field{
+	"store:": Store,
+	"@":      Store,
+	"dir:":   Dir,
+}
+
+// Term is one term of a query.
+type Term struct {
+	Field Field
+	// Text is what is matched, in lower case and without the operators.
+	Text string
+	// Negated terms exclude the entries they match.
+	Negated bool
+	// Prefix and Suffix anchor a path term to the start or end of the path,
+	// which must then contain Text exactly rather than fuzzily.
+	Prefix, Suffix bool
+}
+
+// Query is a parsed query, matching entries matched by all its terms.
+type Query struct {
+	Terms []Term
+}
+
+// Parse parses query. Terms made only of operators, such as a lone "-",
+// are matched literally, so that typing a query never fails.
+func Parse(query string) Query {
+	var q Query
+	for _, s := range strings.Fields(strings.ToLower(query)) {
+		q.Terms = append(q.Terms, parseTerm(s))
+	}
+	return q
+}
+
+func parseTerm(s string) Term {
+	t := Term{Text: s}
+	if rest, ok := strings.CutPrefix(s, "-"); ok && rest != "" {
+		t.Negated, s = true, rest
+	}
+	for prefix, field := range fields {
+		if value, ok := strings.CutPrefix(s, prefix); ok && value != "" {
+			t.Field = field
+			t.Text = strings.Trim(value, "/")
+			if t.Text == "" {
+				// "dir:/" is the root folder, which contains everything
+				t.Text = "/"
+			}
+			return t
+		}
+	}
+	if rest, ok := strings.CutPrefix(s, "^"); ok && rest != "" {
+		t.Prefix, s = true, rest
+	}
+	if rest, ok := strings.CutSuffix(s, "$"); ok && rest != "" {
+		t.Suffix, s = true, rest
+	}
+	if t.Negated || t.Prefix || t.Suffix {
+		t.Text = s
+	}
+	return t
+}
+
+// Empty reports whether q has no terms and so matches everything.
+func (q Query) Empty() bool {
+	return len(q.Terms) == 0
+}
+
+// Match returns whether q matches entry, ignoring case, and the score of
+// the match: the sum of the fuzzy scores of its path terms.
+func (q Query) Match(entry string) (int, bool) {
+	entry = strings.ToLower(entry)
+	total := 0
+	for _, t := range q.Terms {
+		score, ok := t.match(entry)
+		if ok == t.Negated {
+			return 0, false
+		}
+		if !t.Negated {
+			total += score
+		}
+	}
+	return total, true
+}
+
+// match returns whether t matches the lower case entry, ignoring negation,
+// and the score of path terms.
+func (t Term) match(entry string) (int, bool) {
+	switch t.Field {
+	case Store:
+		store, _, ok := strings.Cut(entry, "/")
+		return 0, ok && store == t.Text
+	case Dir:
+		if t.Text == "/" {
+			return 0, true
+		}
+		i := strings.LastIndexByte(entry, '/')
+		if i < 0 {
+			return 0, false
+		}
+		return 0, strings.Contains("/"+entry[:i+1], "/"+t.Text+"/")
+	}
+	switch {
+	case t.Prefix && t.Suffix:
+		if entry != t.Text {
+			return 0, false
+		}
+	case t.Prefix:
+		if !strings.HasPrefix(entry, t.Text) {
+			return 0, false
+		}
+	case t.Suffix:
+		if !strings.HasSuffix(entry, t.Text) {
+			return 0, false
+		}
+	case t.Negated:
+		// excluding fuzzy matches would exclude far too much
+		return 0, strings.Contains(entry, t.Text)
+	}
+	return fuzzy.Score(t.Text, entry)
+}
@@ -0,0 +1,101 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for query, want := range map[string][]Term{
+		"":                 nil,
+		"   ":              nil,
+		"GitHub":           {{Text: "github"}},
+		"work  aws":        {{Text: "work"}, {Text: "aws"}},
+		"-staging":         {{Text: "staging", Negated: true}},
+		"^websites/":       {{Text: "websites/", Prefix: true}},
+		"prod$":            {{Text: "prod", Suffix: true}},
+		"^me$":             {{Text: "me", Prefix: true, Suffix: true}},
+		"-^archive/":       {{Text: "archive/", Negated: true, Prefix: true}},
+		"store:Work":       {{Field: Store, Text: "work"}},
+		"@work/":           {{Field: Store, Text: "work"}},
+		"dir:infra/":       {{Field: Dir, Text: "infra"}},
+		"dir:/infra/aws/":  {{Field: Dir, Text: "infra/aws"}},
+		"dir:/":            {{Field: Dir, Text: "/"}},
+		"-dir:old":         {{Field: Dir, Text: "old", Negated: true}},
+		"-store:personal":  {{Field: Store, Text: "personal", Negated: true}},
+		"dir:infra aws -x": {{Field: Dir, Text: "infra"}, {Text: "aws"}, {Text: "x", Negated: true}},
+		// lone operators are matched literally
+		"-":      {{Text: "-"}},
+		"^":      {{Text: "^"}},
+		"$":      {{Text: "$"}},
+		"store:": {{Text: "store:"}},
+		"-@":     {{Text: "@", Negated: true}},
+		"-dir:":  {{Text: "dir:", Negated: true}},
+	} {
+		if got := Parse(query).Terms; !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", query, got, want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	entries := []string{
+		"websites/github.com/me",
+		"work/infra/aws/prod",
+		"work/infra/aws/staging",
+		"work/infra/gcp/prod",
+		"personal/aws",
+		"archive/github.com/old",
+		"notes",
+	}
+	for query, want := range map[string][]string{
+		"":                entries,
+		"aws":             {"work/infra/aws/prod", "work/infra/aws/staging", "personal/aws"},
+		"work aws":        {"work/infra/aws/prod", "work/infra/aws/staging"},
+		"aws -staging":    {"work/infra/aws/prod", "personal/aws"},
+		"^websites/":      {"websites/github.com/me"},
+		"-^archive/ git":  {"websites/github.com/me"},
+		"prod$":           {"work/infra/aws/prod", "work/infra/gcp/prod"},
+		"^personal/aws$":  {"personal/aws"},
+		"^personal$":      nil,
+		"store:work prod": {"work/infra/aws/prod", "work/infra/gcp/prod"},
+		"store:aws":       nil,
+		"@work gcp":       {"work/infra/gcp/prod"},
+		"-store:work":     {"websites/github.com/me", "personal/aws", "archive/github.com/old", "notes"},
+		"dir:infra/":      {"work/infra/aws/prod", "work/infra/aws/staging", "work/infra/gcp/prod"},
+		"dir:aws":         {"work/infra/aws/prod", "work/infra/aws/staging"},
+		"dir:infra/aws":   {"work/infra/aws/prod", "work/infra/aws/staging"},
+		"dir:fra":         nil,
+		"dir:/ -dir:work": {"websites/github.com/me", "personal/aws", "archive/github.com/old", "notes"},
+		"dir:/ note":      {"notes"},
+		"DIR:ARCHIVE old": {"archive/github.com/old"},
+		"-":               nil,
+	} {
+		q := Parse(query)
+		var got []string
+		for _, e := range entries {
+			if _, ok := q.Match(e); ok {
+				got = append(got, e)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(%q) matches %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestMatchScore(t *testing.T) {
+	// all path terms add to the score, filters and negations don't
+	a, _ := Parse("work aws").Match("work/infra/aws/prod")
+	b, _ := Parse("aws").Match("work/infra/aws/prod")
+	if a <= b {
+		t.Errorf("more matched terms should score higher: %d <= %d", a, b)
+	}
+	c, _ := Parse("aws store:work -staging dir:infra").Match("work/infra/aws/prod")
+	if c != b {
+		t.Errorf("filters changed the score: %d != %d", c, b)
+	}
+	if s, ok := Parse("").Match("anything"); !ok || s != 0 {
+		t.Errorf("empty query = %d, %v, want 0, true", s, ok)
+	}
+}
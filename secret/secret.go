@@ -0,0 +1,104 @@
+// Package secret parses decrypted gopass secrets: a password on the first line,
+// optionally followed by "key: value" lines, possibly after a YAML "---" separator.
+package secret
+
+import (
+	"strings"
+)
+
+// Secret is a parsed gopass secret.
+type Secret struct {
+	Password string
+	// Body holds the lines after the password that are not key/value pairs.
+	Body string
+
+	keys   []string
+	values map[string]string
+}
+
+// Parse parses the raw content of a secret as printed by "gopass show -n".
+func Parse(data []byte) *Secret {
+	s := &Secret{values: make(map[string]string)}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	s.Password = strings.TrimSuffix(lines[0], "\r")
+
+	var body []string
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		if line == "---" {
+			continue
+		}
+		key, value, ok := splitKeyValue(line)
+		if !ok {
+			body = append(body, line)
+			continue
+		}
+		if value == "|" || value == ">" {
+			// YAML block scalar: the value is made of the following indented lines
+			var block []string
+			for i+1 < len(lines) && isIndented(lines[i+1]) {
+				i++
+				block = append(block, strings.TrimSpace(lines[i]))
+			}
+			sep := "\n"
+			if value == ">" {
+				sep = " "
+			}
+			value = strings.Join(block, sep)
+		}
+		s.set(key, unquote(value))
+	}
+	s.Body = strings.Join(body, "\n")
+	return s
+}
+
+// Keys returns the keys of the secret in the order they appear in it.
+func (s *Secret) Keys() []string {
+	return s.keys
+}
+
+// Get returns the value of key, which is case-insensitive like in gopass.
+func (s *Secret) Get(key string) (string, bool) {
+	v, ok := s.values[strings.ToLower(key)]
+	return v, ok
+}
+
+func (s *Secret) set(key, value string) {
+	key = strings.ToLower(key)
+	if _, ok := s.values[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.values[key] = value
+}
+
+// splitKeyValue splits a "key: value" line. Like in YAML the colon must be
+// followed by a space or end the line, so that URLs are not mistaken for keys.
+func splitKeyValue(line string) (key, value string, ok bool) {
+	i := strings.IndexByte(line, ':')
+	if i <= 0 || isIndented(line) {
+		return "", "", false
+	}
+	key = line[:i]
+	if strings.ContainsAny(key, " \t") {
+		return "", "", false
+	}
+	rest := line[i+1:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(rest), true
+}
+
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 {
+		if q := value[0]; (q == '"' || q == '\'') && value[len(value)-1] == q {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
@@ -0,0 +1,85 @@
+package secret
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePasswordOnly(t *testing.T) {
+	s := Parse([]byte("hunter2\n"))
+	if s.Password != "hunter2" {
+		t.Errorf("Password = %q, want %q", s.Password, "hunter2")
+	}
+	if len(s.Keys()) != 0 {
+		t.Errorf("Keys() = %q, want none", s.Keys())
+	}
+	if s.Body != "" {
+		t.Errorf("Body = %q, want empty", s.Body)
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	s := Parse([]byte("hunter2\nusername: me\nURL: https://github.com/login\nemail: \"me@example.com\"\nsome notes\n"))
+
+	if s.Password != "hunter2" {
+		t.Errorf("Password = %q, want %q", s.Password, "hunter2")
+	}
+	if want := []string{"username", "url", "email"}; !reflect.DeepEqual(s.Keys(), want) {
+		t.Errorf("Keys() = %q, want %q", s.Keys(), want)
+	}
+	for key, want := range map[string]string{
+		"username": "me",
+		"url":      "https://github.com/login",
+		"Url":      "https://github.com/login",
+		"email":    "me@example.com",
+	} {
+		if got, ok := s.Get(key); !ok || got != want {
+			t.Errorf("Get(%q) = %q, %v, want %q", key, got, ok, want)
+		}
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Error("Get(missing) should not be found")
+	}
+	if s.Body != "some notes" {
+		t.Errorf("Body = %q, want %q", s.Body, "some notes")
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	s := Parse([]byte("hunter2\n---\nuser: me\nnotes: |\n  first line\n  second line\nfolded: >\n  a\n  b\nempty:\n"))
+
+	if want := []string{"user", "notes", "folded", "empty"}; !reflect.DeepEqual(s.Keys(), want) {
+		t.Errorf("Keys() = %q, want %q", s.Keys(), want)
+	}
+	for key, want := range map[string]string{
+		"user":   "me",
+		"notes":  "first line\nsecond line",
+		"folded": "a b",
+		"empty":  "",
+	} {
+		if got, ok := s.Get(key); !ok || got != want {
+			t.Errorf("Get(%q) = %q, %v, want %q", key, got, ok, want)
+		}
+	}
+}
+
+func TestParseNotKeys(t *testing.T) {
+	s := Parse([]byte("pw\notpauth://totp/me?secret=ABC\nhttps://example.com\nnot a: key\n  indented: value\r\n"))
+
+	if len(s.Keys()) != 0 {
+		t.Errorf("Keys() = %q, want none", s.Keys())
+	}
+	if want := "otpauth://totp/me?secret=ABC\nhttps://example.com\nnot a: key\n  indented: value"; s.Body != want {
+		t.Errorf("Body = %q, want %q", s.Body, want)
+	}
+}
+
+func TestParseDuplicateKeyKeepsLast(t *testing.T) {
+	s := Parse([]byte("pw\nuser: a\nUser: b\n"))
+	if want := []string{"user"}; !reflect.DeepEqual(s.Keys(), want) {
+		t.Errorf("Keys() = %q, want %q", s.Keys(), want)
+	}
+	if got, _ := s.Get("user"); got != "b" {
+		t.Errorf("Get(user) = %q, want %q", got, "b")
+	}
+}
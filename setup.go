@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/config"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/doctor"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/install"
+)
+
+// parseSetupArgs parses the flags shared by the install and uninstall
+// subcommands and returns the plugin directory they select. Usage errors are
+// already reported on stderr.
+func parseSetupArgs(name string, args []string, stderr io.Writer) (dir string, code int, ok bool) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: cosmic-gopass-plugin %s [--prefix DIR] [--system]\n", name)
+		fs.PrintDefaults()
+	}
+	prefix := fs.String("prefix", "", "install under `DIR`/pop-launcher/plugins instead of $XDG_DATA_HOME")
+	system := fs.Bool("system", false, "install for all users under /usr/lib/pop-launcher/plugins")
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return "", 0, false
+	} else if err != nil {
+		return "", 2, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected arguments %q\n", fs.Args())
+		fs.Usage()
+		return "", 2, false
+	}
+	dir, err := install.Dir(*prefix, *system)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return "", 1, false
+	}
+	return dir, 0, true
+}
+
+// runInstall implements the install subcommand, which copies the running
+// binary and a plugin.ron matching the configuration where pop-launcher finds them.
+func runInstall(args []string, stdout, stderr io.Writer) int {
+	dir, code, ok := parseSetupArgs("install", args, stderr)
+	if !ok {
+		return code
+	}
+	// unlike the plugin, refuse to install a plugin.ron from a broken configuration
+	c, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(stderr, "install: %v\n", err)
+		return 1
+	}
+	bin, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(stderr, "install: locating the plugin binary: %v\n", err)
+		return 1
+	}
+	if err := install.Install(dir, bin, c); err != nil {
+		fmt.Fprintf(stderr, "install: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Installed the gopass plugin in %s, type %q in the launcher to use it.\n", dir, c.Prefix)
+	return 0
+}
+
+// runUninstall implements the uninstall subcommand.
+func runUninstall(args []string, stdout, stderr io.Writer) int {
+	dir, code, ok := parseSetupArgs("uninstall", args, stderr)
+	if !ok {
+		return code
+	}
+	if err := install.Uninstall(dir); err != nil {
+		fmt.Fprintf(stderr, "uninstall: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Removed the gopass plugin from %s.\n", dir)
+	return 0
+}
+
+// runDoctor implements the doctor subcommand, which checks every step from
+// finding gopass to the launcher sending queries to the plugin.
+func runDoctor(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+
+	c, err := config.Load()
+	cfg = c
+	results := doctor.Run(doctor.System(cfg, err, findGopass()))
+	write := doctor.WriteText
+	if *asJSON {
+		write = doctor.WriteJSON
+	}
+	if err := write(stdout, results); err != nil {
+		fmt.Fprintf(stderr, "doctor: %v\n", err)
+		return 1
+	}
+	if doctor.Failed(results) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallUsesConfiguredPrefix(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmp)
+	if err := os.MkdirAll(filepath.Join(tmp, "cosmic-gopass-plugin"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "cosmic-gopass-plugin", "config.json"), []byte(`{"prefix": "pw "}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runInstall([]string{"--prefix", tmp}, &stdout, &stderr); code != 0 {
+		t.Fatalf("install exited with %d: %s", code, stderr.String())
+	}
+	dir := filepath.Join(tmp, "pop-launcher", "plugins", "gopass")
+	ron, err := os.ReadFile(filepath.Join(dir, "plugin.ron"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(ron), `regex: "^(pw |otp )",`) {
+		t.Errorf("plugin.ron doesn't match the configured prefix:\n%s", ron)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cosmic-gopass-plugin")); err != nil {
+		t.Errorf("binary not installed: %v", err)
+	}
+
+	if code := runUninstall([]string{"--prefix", tmp}, &stdout, &stderr); code != 0 {
+		t.Fatalf("uninstall exited with %d: %s", code, stderr.String())
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("plugin still installed: %v", err)
+	}
+}
+
+func TestInstallRejectsInvalidConfig(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmp)
+	if err := os.MkdirAll(filepath.Join(tmp, "cosmic-gopass-plugin"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "cosmic-gopass-plugin", "config.json"), []byte(`{"prefix": ""}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runInstall([]string{"--prefix", tmp}, &stdout, &stderr); code != 1 {
+		t.Errorf("install exited with %d, want 1", code)
+	}
+	if !strings.Contains(stderr.String(), "prefix must not be empty") {
+		t.Errorf("unexpected error output %q", stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "pop-launcher")); !os.IsNotExist(err) {
+		t.Errorf("something was installed: %v", err)
+	}
+}
+
+func TestSetupUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runUninstall([]string{"extra"}, &stdout, &stderr); code != 2 {
+		t.Errorf("uninstall with arguments exited with %d, want 2", code)
+	}
+	if code := runInstall([]string{"-h"}, &stdout, &stderr); code != 0 {
+		t.Errorf("install -h exited with %d, want 0", code)
+	}
+	if !strings.Contains(stderr.String(), "Usage: cosmic-gopass-plugin install") {
+		t.Errorf("no usage printed: %q", stderr.String())
+	}
+}
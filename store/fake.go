@@ -0,0 +1,97 @@
+package store
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/otp"
+)
+
+// Fake is an in-memory store for tests, holding the content of its entries.
+type Fake struct {
+	mu      sync.Mutex
+	secrets map[string]string
+	err     error
+	shown   []string
+}
+
+// NewFake returns a store of secrets, by entry name.
+func NewFake(secrets map[string]string) *Fake {
+	return &Fake{secrets: maps.Clone(secrets)}
+}
+
+// Fail makes all the following calls return err, or succeed again if nil.
+func (f *Fake) Fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Set adds or replaces the secret of entry.
+func (f *Fake) Set(entry, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets[entry] = content
+}
+
+// Shown returns the entries decrypted so far.
+func (f *Fake) Shown() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.shown)
+}
+
+// List returns the sorted entries.
+func (f *Fake) List() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return slices.Sorted(maps.Keys(f.secrets)), nil
+}
+
+// Show returns the content of entry.
+func (f *Fake) Show(entry string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	content, ok := f.secrets[entry]
+	if !ok {
+		return nil, fmt.Errorf("entry %s is %w", entry, ErrNotFound)
+	}
+	f.shown = append(f.shown, entry)
+	return []byte(content), nil
+}
+
+// ShowKey returns the password or key of entry.
+func (f *Fake) ShowKey(entry, key string) (string, error) {
+	return showKey(f, entry, key)
+}
+
+// OTP returns the OTP parameters of entry.
+func (f *Fake) OTP(entry string) (*otp.Key, error) {
+	return showOTP(f, entry)
+}
+
+// Generate adds entry with a random password.
+func (f *Fake) Generate(entry string, length int) (string, error) {
+	password, err := generatePassword(length)
+	if err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return "", f.err
+	}
+	if _, ok := f.secrets[entry]; ok {
+		return "", fmt.Errorf("entry %s already exists", entry)
+	}
+	f.secrets[entry] = password
+	return password, nil
+}
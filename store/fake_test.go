@@ -0,0 +1,58 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestFake(t *testing.T) {
+	f := NewFake(map[string]string{
+		"websites/github.com": "hunter2\nusername: me\notpauth://totp/me?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&period=60",
+		"email/work":          "secret",
+		"bank":                "p4ss\notpauth://hotp/bank?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=3",
+	})
+	if entries, err := f.List(); err != nil || !slices.Equal(entries, []string{"bank", "email/work", "websites/github.com"}) {
+		t.Errorf("List = %q, %v", entries, err)
+	}
+	for key, want := range map[string]string{"": "hunter2", "username": "me"} {
+		if got, err := f.ShowKey("websites/github.com", key); err != nil || got != want {
+			t.Errorf("ShowKey(%q) = %q, %v, want %q", key, got, err, want)
+		}
+	}
+	if _, err := f.ShowKey("websites/github.com", "email"); err == nil {
+		t.Error("ShowKey of a missing key should fail")
+	}
+	if key, err := f.OTP("websites/github.com"); err != nil || key.Period != 60 {
+		t.Errorf("OTP = %+v, %v", key, err)
+	}
+	if _, err := f.OTP("email/work"); err == nil {
+		t.Error("OTP of an entry without OTP should fail")
+	}
+	if _, err := f.OTP("bank"); !errors.Is(err, ErrHOTP) {
+		t.Errorf("OTP of an HOTP key = %v, want %v", err, ErrHOTP)
+	}
+	if _, err := f.Show("missing"); err == nil {
+		t.Error("Show of a missing entry should fail")
+	}
+	if got := f.Shown(); len(got) != 6 {
+		t.Errorf("Shown = %q, want the 6 entries shown", got)
+	}
+
+	password, err := f.Generate("email/new", 20)
+	if err != nil || len(password) != 20 {
+		t.Errorf("Generate = %q, %v", password, err)
+	}
+	if got, err := f.ShowKey("email/new", ""); err != nil || got != password {
+		t.Errorf("generated password = %q, %v, want %q", got, err, password)
+	}
+	if _, err := f.Generate("email/work", 20); err == nil {
+		t.Error("Generate of an existing entry should fail")
+	}
+
+	failure := errors.New("locked")
+	f.Fail(failure)
+	if _, err := f.List(); !errors.Is(err, failure) {
+		t.Errorf("List = %v, want %v", err, failure)
+	}
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/otp"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/watch"
+)
+
+// Gopass is the store of the gopass CLI.
+type Gopass struct {
+	// Path is the path of the gopass binary.
+	Path string
+	// Sync synchronises the stores with their remotes on each command.
+	Sync bool
+}
+
+// command returns the command running gopass with args, without
+// synchronising the store unless configured to.
+func (g *Gopass) command(args ...string) *exec.Cmd {
+	if !g.Sync {
+		args = append([]string{"--nosync"}, args...)
+	}
+	return exec.Command(g.Path, args...)
+}
+
+// List runs "gopass ls -flat".
+func (g *Gopass) List() ([]string, error) {
+	out, err := g.command("ls", "-flat").Output()
+	if err != nil {
+		return nil, &CommandError{Command: "gopass ls", Err: err}
+	}
+	var entries []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			entries = append(entries, line)
+		}
+	}
+	return entries, nil
+}
+
+// Show runs "gopass show -n", without copying the password.
+func (g *Gopass) Show(entry string) ([]byte, error) {
+	out, err := g.command("show", "-C=false", "-n", entry).Output()
+	if err != nil {
+		return nil, &CommandError{Command: "gopass show -n", Err: err}
+	}
+	return out, nil
+}
+
+// ShowKey runs "gopass show -o", letting gopass parse the secret.
+func (g *Gopass) ShowKey(entry, key string) (string, error) {
+	args := []string{"show", "-C=false", "-c=false", "-o", entry}
+	if key != "" {
+		args = append(args, key)
+	}
+	out, err := g.command(args...).Output()
+	if err != nil {
+		return "", &CommandError{Command: "gopass show -o", Err: err}
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// OTP parses the OTP parameters of the secret of entry, rather than running
+// "gopass otp" which increments HOTP counters.
+func (g *Gopass) OTP(entry string) (*otp.Key, error) {
+	return showOTP(g, entry)
+}
+
+// Generate runs "gopass generate", without copying nor printing the
+// password, and then shows it.
+func (g *Gopass) Generate(entry string, length int) (string, error) {
+	if err := g.command("generate", "-c=false", "-p=false", entry, strconv.Itoa(length)).Run(); err != nil {
+		return "", &CommandError{Command: "gopass generate", Err: err}
+	}
+	return g.ShowKey(entry, "")
+}
+
+// Dirs returns the root store and the mounted stores of gopass, from the
+// paths listed by "gopass config".
+func (g *Gopass) Dirs() ([]watch.Store, error) {
+	out, err := g.command("config").Output()
+	if err != nil {
+		return nil, &CommandError{Command: "gopass config", Err: err}
+	}
+	return parseStores(string(out)), nil
+}
+
+// parseStores parses the store paths of the output of "gopass config", such as
+//
+//	mounts.path = /home/me/.local/share/gopass/stores/root
+//	mounts.work.path = /home/me/.local/share/gopass/stores/work
+//
+// The root store defaults to the gopass default when its path isn't listed.
+func parseStores(config string) []watch.Store {
+	var root string
+	var stores []watch.Store
+	scanner := bufio.NewScanner(strings.NewReader(config))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), storePath(strings.TrimSpace(value))
+		switch {
+		case key == "mounts.path" || key == "path":
+			root = value
+		case strings.HasPrefix(key, "mounts.") && strings.HasSuffix(key, ".path"):
+			mount := strings.TrimSuffix(strings.TrimPrefix(key, "mounts."), ".path")
+			stores = append(stores, watch.Store{Mount: mount, Dir: value})
+		}
+	}
+	if root == "" {
+		root = defaultStore()
+	}
+	return append([]watch.Store{{Dir: root}}, stores...)
+}
+
+// storePath returns the directory of a store path, which older gopass
+// versions prefix with the backends and a "file://" scheme.
+func storePath(path string) string {
+	if _, p, ok := strings.Cut(path, "file://"); ok {
+		path = p
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// defaultStore returns the default directory of the root store of gopass.
+func defaultStore() string {
+	if dir := os.Getenv("PASSWORD_STORE_DIR"); dir != "" {
+		return dir
+	}
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, _ := os.UserHomeDir()
+		data = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(data, "gopass", "stores", "root")
+}
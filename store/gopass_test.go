@@ -0,0 +1,100 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/watch"
+)
+
+func TestParseStores(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	t.Setenv("PASSWORD_STORE_DIR", "")
+	t.Setenv("XDG_DATA_HOME", "")
+
+	got := parseStores(`core.autoclip = true
+core.notifications = false
+mounts.path = /home/me/.local/share/gopass/stores/root
+mounts.work.path = /home/me/.local/share/gopass/stores/work
+mounts.shared/team.path = gpgcli-gitcli-fs+file://~/team
+`)
+	want := []watch.Store{
+		{Dir: "/home/me/.local/share/gopass/stores/root"},
+		{Mount: "work", Dir: "/home/me/.local/share/gopass/stores/work"},
+		{Mount: "shared/team", Dir: "/home/me/team"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStores = %+v, want %+v", got, want)
+	}
+
+	if got := parseStores("core.autoclip = true\n"); !reflect.DeepEqual(got, []watch.Store{{Dir: "/home/me/.local/share/gopass/stores/root"}}) {
+		t.Errorf("parseStores without paths = %+v", got)
+	}
+	t.Setenv("PASSWORD_STORE_DIR", "/srv/pass")
+	if got := parseStores(""); !reflect.DeepEqual(got, []watch.Store{{Dir: "/srv/pass"}}) {
+		t.Errorf("parseStores with PASSWORD_STORE_DIR = %+v", got)
+	}
+}
+
+// fakeGopass returns a gopass script logging its arguments to a file and
+// printing output.
+func fakeGopass(t *testing.T, output string) (*Gopass, func() []string) {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "args")
+	script := "#!/bin/sh\necho \"$@\" >> " + log + "\nprintf '%s' '" + output + "'\n"
+	path := filepath.Join(dir, "gopass")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return &Gopass{Path: path}, func() []string {
+		data, _ := os.ReadFile(log)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func TestGopassCommands(t *testing.T) {
+	g, args := fakeGopass(t, "websites/github.com\nemail/work\n\n")
+	entries, err := g.List()
+	if err != nil || !slices.Equal(entries, []string{"websites/github.com", "email/work"}) {
+		t.Errorf("List = %q, %v", entries, err)
+	}
+	g.Show("email/work")
+	g.ShowKey("email/work", "")
+	g.ShowKey("email/work", "username")
+	g.Generate("email/new", 24)
+	g.Sync = true
+	g.List()
+	want := []string{
+		"--nosync ls -flat",
+		"--nosync show -C=false -n email/work",
+		"--nosync show -C=false -c=false -o email/work",
+		"--nosync show -C=false -c=false -o email/work username",
+		"--nosync generate -c=false -p=false email/new 24",
+		"--nosync show -C=false -c=false -o email/new",
+		"ls -flat",
+	}
+	if got := args(); !slices.Equal(got, want) {
+		t.Errorf("gopass was run with %q, want %q", got, want)
+	}
+}
+
+func TestGopassShow(t *testing.T) {
+	g, _ := fakeGopass(t, "hunter2\ntotp: GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n")
+	if value, err := g.ShowKey("e", ""); err != nil || value != "hunter2\ntotp: GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" {
+		t.Errorf("ShowKey = %q, %v", value, err)
+	}
+	key, err := g.OTP("e")
+	if err != nil || key.Period != 30 {
+		t.Errorf("OTP = %+v, %v", key, err)
+	}
+
+	g.Path = filepath.Join(t.TempDir(), "missing")
+	if _, err := g.Show("e"); err == nil || !strings.Contains(err.Error(), "gopass show -n failed") {
+		t.Errorf("Show with a missing gopass = %v", err)
+	}
+}
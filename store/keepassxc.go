@@ -0,0 +1,156 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/otp"
+)
+
+// KeePassXC is a KeePass database read with keepassxc-cli. Its entries are
+// named after their group and title, like "Internet/GitHub".
+//
+// Each command unlocks the database again, as keepassxc-cli keeps no agent:
+// with the key file alone, or with the password printed by PasswordCommand.
+type KeePassXC struct {
+	// CLI is the path of the keepassxc-cli binary.
+	CLI string
+	// Database is the path of the .kdbx database.
+	Database string
+	// KeyFile is the key file unlocking the database, if any.
+	KeyFile string
+	// PasswordCommand is a shell command printing the password of the
+	// database, which is only protected by KeyFile when empty.
+	PasswordCommand string
+	// RecycleBin is the name of the group of the deleted entries, which
+	// KeePassXC names in the language of its user interface.
+	RecycleBin string
+}
+
+// DefaultRecycleBin is the English name of the recycle bin of KeePassXC.
+const DefaultRecycleBin = "Recycle Bin"
+
+// NewKeePassXC returns the database at path, unlocked with keyFile and the
+// password printed by passwordCommand, with the English recycle bin.
+func NewKeePassXC(path, keyFile, passwordCommand string) *KeePassXC {
+	return &KeePassXC{CLI: "keepassxc-cli", Database: path, KeyFile: keyFile, PasswordCommand: passwordCommand, RecycleBin: DefaultRecycleBin}
+}
+
+// command returns the keepassxc-cli command running name on the database
+// with args, reading the password from its stdin.
+func (k *KeePassXC) command(name string, args ...string) (*exec.Cmd, error) {
+	flags := []string{name, "--quiet"}
+	if k.KeyFile != "" {
+		flags = append(flags, "--key-file", k.KeyFile)
+	}
+	if k.PasswordCommand == "" {
+		flags = append(flags, "--no-password")
+	}
+	cmd := exec.Command(k.CLI, append(append(flags, k.Database), args...)...)
+	if k.PasswordCommand != "" {
+		password, err := exec.Command("sh", "-c", k.PasswordCommand).Output()
+		if err != nil {
+			return nil, &CommandError{Command: "keepassxc_password_command", Err: err}
+		}
+		cmd.Stdin = bytes.NewReader(password)
+	}
+	return cmd, nil
+}
+
+// List runs "keepassxc-cli ls -R -f", skipping the groups and the recycle bin.
+func (k *KeePassXC) List() ([]string, error) {
+	cmd, err := k.command("ls", "--recursive", "--flatten")
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, &CommandError{Command: "keepassxc-cli ls", Err: err}
+	}
+	var entries []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		// empty groups are listed as "Group/[empty]"
+		if line == "" || strings.HasSuffix(line, "/") || line == "[empty]" || strings.HasSuffix(line, "/[empty]") ||
+			k.RecycleBin != "" && strings.HasPrefix(line, k.RecycleBin+"/") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries, nil
+}
+
+// attributes are the attributes of the KeePass entries shown by Show, with
+// the keys they get in the secret after the password.
+var attributes = []struct{ name, key string }{
+	{"Password", ""},
+	{"UserName", "username"},
+	{"URL", "url"},
+	{"otp", "totp"},
+}
+
+// Show runs "keepassxc-cli show -a Password -a UserName -a URL -a otp", and
+// writes the attributes like a gopass secret: the password followed by the
+// username, url and totp keys that are set.
+func (k *KeePassXC) Show(entry string) ([]byte, error) {
+	args := []string{"--show-protected"}
+	for _, attr := range attributes {
+		args = append(args, "--attributes", attr.name)
+	}
+	cmd, err := k.command("show", append(args, entry)...)
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.Output()
+	values := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		stderr := string(exitErr.Stderr)
+		switch {
+		case strings.Contains(stderr, "Could not find entry"):
+			return nil, fmt.Errorf("entry %s is %w", entry, ErrNotFound)
+		// the other attributes are still shown when the entry has no OTP
+		case strings.Contains(stderr, "unknown attribute otp") && len(values) == len(attributes)-1:
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, &CommandError{Command: "keepassxc-cli show", Err: err}
+	}
+	var sec strings.Builder
+	for i, value := range values[:min(len(values), len(attributes))] {
+		switch key := attributes[i].key; {
+		case key == "":
+			sec.WriteString(value + "\n")
+		case value != "":
+			fmt.Fprintf(&sec, "%s: %s\n", key, value)
+		}
+	}
+	return []byte(sec.String()), nil
+}
+
+// Generate runs "keepassxc-cli add --generate", and then shows the password.
+func (k *KeePassXC) Generate(entry string, length int) (string, error) {
+	cmd, err := k.command("add", "--generate", "--length", strconv.Itoa(length), entry)
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Run(); err != nil {
+		return "", &CommandError{Command: "keepassxc-cli add", Err: err}
+	}
+	return k.ShowKey(entry, "")
+}
+
+// ShowKey returns the password, username, url or totp of entry.
+func (k *KeePassXC) ShowKey(entry, key string) (string, error) {
+	return showKey(k, entry, key)
+}
+
+// OTP parses the otpauth URI that KeePassXC stores in the otp attribute.
+func (k *KeePassXC) OTP(entry string) (*otp.Key, error) {
+	return showOTP(k, entry)
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeKeePassXC is a keepassxc-cli printing a sample database, with a
+// recycle bin named in German and a group named like the English one, which
+// checks the password read on its stdin
+// when not given --no-password.
+const fakeKeePassXC = `#!/bin/sh
+cmd=$1
+for arg; do
+	entry=$arg
+	[ "$arg" = --no-password ] && nopassword=1
+done
+if [ -z "$nopassword" ]; then
+	read -r password
+	if [ "$password" != "s3cret" ]; then
+		echo "Error while reading the database: Invalid credentials were provided, please try again." >&2
+		exit 1
+	fi
+fi
+case $cmd in
+add)
+	[ "$entry" = "Internet/New" ] || exit 1
+	;;
+ls)
+	printf 'Internet/\nInternet/GitHub\nInternet/Old/\nInternet/Old/[empty]\nWork VPN\nPapierkorb/\nPapierkorb/Gone\nRecycle Bin/\nRecycle Bin/Gone\n'
+	;;
+show)
+	case $entry in
+	Internet/GitHub)
+		printf 'hunter2\nme\nhttps://github.com\notpauth://totp/me?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n'
+		;;
+	"Work VPN" | Internet/New)
+		printf 'p4ss\n\n\n'
+		echo "ERROR: unknown attribute otp." >&2
+		exit 1
+		;;
+	*)
+		echo "Could not find entry with path $entry." >&2
+		exit 1
+		;;
+	esac
+	;;
+esac
+`
+
+func newFakeKeePassXC(t *testing.T) *KeePassXC {
+	t.Helper()
+	cli := filepath.Join(t.TempDir(), "keepassxc-cli")
+	if err := os.WriteFile(cli, []byte(fakeKeePassXC), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	k := NewKeePassXC("/home/me/work.kdbx", "", "echo s3cret")
+	k.CLI = cli
+	return k
+}
+
+func TestKeePassXC(t *testing.T) {
+	k := newFakeKeePassXC(t)
+	entries, err := k.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := []string{"Internet/GitHub", "Work VPN", "Papierkorb/Gone"}; !slices.Equal(entries, want) {
+		t.Errorf("List = %q, want %q", entries, want)
+	}
+	k.RecycleBin = "Papierkorb"
+	if entries, err := k.List(); err != nil || !slices.Equal(entries, []string{"Internet/GitHub", "Work VPN", "Recycle Bin/Gone"}) {
+		t.Errorf("List with a German recycle bin = %q, %v", entries, err)
+	}
+
+	want := "hunter2\nusername: me\nurl: https://github.com\ntotp: otpauth://totp/me?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n"
+	if raw, err := k.Show("Internet/GitHub"); err != nil || string(raw) != want {
+		t.Errorf("Show = %q, %v, want %q", raw, err, want)
+	}
+	if raw, err := k.Show("Work VPN"); err != nil || string(raw) != "p4ss\n" {
+		t.Errorf("Show of an entry without OTP = %q, %v", raw, err)
+	}
+	if value, err := k.ShowKey("Internet/GitHub", "url"); err != nil || value != "https://github.com" {
+		t.Errorf("ShowKey = %q, %v", value, err)
+	}
+	if key, err := k.OTP("Internet/GitHub"); err != nil || key.Digits != 6 {
+		t.Errorf("OTP = %+v, %v", key, err)
+	}
+	if _, err := k.OTP("Work VPN"); err == nil {
+		t.Error("OTP of an entry without OTP should fail")
+	}
+	if _, err := k.Show("Internet/Missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Show of a missing entry = %v", err)
+	}
+	if password, err := k.Generate("Internet/New", 20); err != nil || password != "p4ss" {
+		t.Errorf("Generate = %q, %v", password, err)
+	}
+	if _, err := k.Generate("Internet/GitHub", 20); err == nil || !strings.Contains(err.Error(), "keepassxc-cli add failed") {
+		t.Errorf("Generate of an existing entry = %v", err)
+	}
+}
+
+func TestKeePassXCUnlock(t *testing.T) {
+	k := newFakeKeePassXC(t)
+	k.PasswordCommand = "echo wrong"
+	if _, err := k.List(); err == nil || !strings.Contains(err.Error(), "keepassxc-cli ls failed") {
+		t.Errorf("List with a wrong password = %v", err)
+	}
+	k.PasswordCommand = "exit 1"
+	if _, err := k.List(); err == nil || !strings.Contains(err.Error(), "keepassxc_password_command failed") {
+		t.Errorf("List with a failing password command = %v", err)
+	}
+	// a database protected by a key file only
+	k.KeyFile, k.PasswordCommand = "/home/me/work.keyx", ""
+	if entries, err := k.List(); err != nil || len(entries) != 3 {
+		t.Errorf("List with --no-password = %q, %v", entries, err)
+	}
+}
+
+// TestKeePassXCDatabase reads testdata/test.kdbx, unlocked by "s3cret", with
+// the keepassxc-cli installed.
+func TestKeePassXCDatabase(t *testing.T) {
+	if _, err := exec.LookPath("keepassxc-cli"); err != nil {
+		t.Skip("keepassxc-cli not installed")
+	}
+	k := NewKeePassXC(filepath.Join("testdata", "test.kdbx"), "", "echo s3cret")
+	k.RecycleBin = "Corbeille"
+	entries, err := k.List()
+	if want := []string{"Internet/GitHub", "Work VPN"}; err != nil || !slices.Equal(entries, want) {
+		t.Errorf("List = %q, %v, want %q", entries, err, want)
+	}
+	want := "hunter2\nusername: me\nurl: https://github.com\ntotp: otpauth://totp/me?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n"
+	if raw, err := k.Show("Internet/GitHub"); err != nil || string(raw) != want {
+		t.Errorf("Show = %q, %v, want %q", raw, err, want)
+	}
+	if raw, err := k.Show("Work VPN"); err != nil || string(raw) != "p4ss\n" {
+		t.Errorf("Show of an entry without OTP = %q, %v", raw, err)
+	}
+	if _, err := k.Show("Internet/Missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Show of a missing entry = %v", err)
+	}
+	k.PasswordCommand = "echo wrong"
+	if _, err := k.List(); err == nil {
+		t.Error("List with a wrong password should fail")
+	}
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/otp"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/watch"
+)
+
+// Source is a store searched along with others in a Multi.
+type Source struct {
+	// Name is the folder the entries of the store are listed in.
+	Name  string
+	Store Store
+}
+
+// Multi federates several stores, listing the entries of each source in a
+// folder named after it, like gopass lists its mounted stores.
+type Multi struct {
+	sources []Source
+}
+
+// NewMulti returns the store federating sources.
+func NewMulti(sources ...Source) *Multi {
+	return &Multi{sources: sources}
+}
+
+// List lists the entries of the sources concurrently. When some of them
+// fail, the entries of the others are returned along with the errors.
+func (m *Multi) List() ([]string, error) {
+	lists := make([][]string, len(m.sources))
+	errs := make([]error, len(m.sources))
+	var wg sync.WaitGroup
+	for i, src := range m.sources {
+		wg.Go(func() {
+			entries, err := src.Store.List()
+			if err != nil {
+				errs[i] = fmt.Errorf("source %s: %w", src.Name, err)
+				return
+			}
+			for _, entry := range entries {
+				lists[i] = append(lists[i], src.Name+"/"+entry)
+			}
+		})
+	}
+	wg.Wait()
+	return slices.Concat(lists...), errors.Join(errs...)
+}
+
+// Source returns the name of the source of entry, or "" if there is none.
+func (m *Multi) Source(entry string) string {
+	src, _, err := m.source(entry)
+	if err != nil {
+		return ""
+	}
+	return src.Name
+}
+
+// source returns the source of entry and the name of entry in its store.
+func (m *Multi) source(entry string) (Source, string, error) {
+	name, rest, ok := strings.Cut(entry, "/")
+	if ok && rest != "" {
+		for _, src := range m.sources {
+			if src.Name == name {
+				return src, rest, nil
+			}
+		}
+	}
+	return Source{}, "", fmt.Errorf("entry %s is %w", entry, ErrNotFound)
+}
+
+// Show returns the secret of entry from its source.
+func (m *Multi) Show(entry string) ([]byte, error) {
+	src, name, err := m.source(entry)
+	if err != nil {
+		return nil, err
+	}
+	return src.Store.Show(name)
+}
+
+// ShowKey returns the value of key in the secret of entry from its source.
+func (m *Multi) ShowKey(entry, key string) (string, error) {
+	src, name, err := m.source(entry)
+	if err != nil {
+		return "", err
+	}
+	return src.Store.ShowKey(name, key)
+}
+
+// OTP returns the OTP parameters of entry from its source.
+func (m *Multi) OTP(entry string) (*otp.Key, error) {
+	src, name, err := m.source(entry)
+	if err != nil {
+		return nil, err
+	}
+	return src.Store.OTP(name)
+}
+
+// Generate creates entry in its source.
+func (m *Multi) Generate(entry string, length int) (string, error) {
+	src, name, err := m.source(entry)
+	if err != nil {
+		return "", err
+	}
+	return src.Store.Generate(name, length)
+}
+
+// Dirs returns the directories of the sources that can be watched, mounted
+// in the folders of their source. When some of them fail, the directories of
+// the others are returned along with the errors.
+func (m *Multi) Dirs() ([]watch.Store, error) {
+	var stores []watch.Store
+	var errs []error
+	for _, src := range m.sources {
+		watchable, ok := src.Store.(Watchable)
+		if !ok {
+			continue
+		}
+		dirs, err := watchable.Dirs()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("source %s: %w", src.Name, err))
+			continue
+		}
+		for _, d := range dirs {
+			d.Mount = path.Join(src.Name, d.Mount)
+			stores = append(stores, d)
+		}
+	}
+	return stores, errors.Join(errs...)
+}
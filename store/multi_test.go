@@ -0,0 +1,75 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/watch"
+)
+
+func TestMulti(t *testing.T) {
+	personal := NewFake(map[string]string{"websites/github.com": "hunter2\nusername: me", "email": "p4ss"})
+	work := NewFake(map[string]string{"websites/github.com": "correct horse\notpauth://totp/me?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"})
+	m := NewMulti(Source{"personal", personal}, Source{"work", work})
+
+	entries, err := m.List()
+	if want := []string{"personal/email", "personal/websites/github.com", "work/websites/github.com"}; err != nil || !slices.Equal(entries, want) {
+		t.Errorf("List = %q, %v, want %q", entries, err, want)
+	}
+	if raw, err := m.Show("work/websites/github.com"); err != nil || !strings.HasPrefix(string(raw), "correct horse\n") {
+		t.Errorf("Show = %q, %v", raw, err)
+	}
+	if value, err := m.ShowKey("personal/websites/github.com", "username"); err != nil || value != "me" {
+		t.Errorf("ShowKey = %q, %v", value, err)
+	}
+	if _, err := m.OTP("work/websites/github.com"); err != nil {
+		t.Errorf("OTP: %v", err)
+	}
+	for _, entry := range []string{"websites/github.com", "team/email", "personal", "personal/"} {
+		if _, err := m.Show(entry); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Show(%q) = %v, want not found", entry, err)
+		}
+	}
+	if password, err := m.Generate("work/vpn", 16); err != nil || len(password) != 16 {
+		t.Errorf("Generate = %q, %v", password, err)
+	}
+	if entries, _ := work.List(); !slices.Contains(entries, "vpn") {
+		t.Errorf("Generate didn't create the entry in its source, which has %q", entries)
+	}
+	if got := m.Source("work/websites/github.com"); got != "work" {
+		t.Errorf("Source = %q", got)
+	}
+	if got := m.Source("team/email"); got != "" {
+		t.Errorf("Source of an entry of no source = %q", got)
+	}
+
+	// the sources that can be listed still are
+	work.Fail(errors.New("store locked"))
+	entries, err = m.List()
+	if err == nil || err.Error() != "source work: store locked" || len(entries) != 2 {
+		t.Errorf("List with a failing source = %q, %v", entries, err)
+	}
+}
+
+func TestMultiDirs(t *testing.T) {
+	m := NewMulti(
+		Source{"personal", &Pass{Dir: "/home/me/.password-store"}},
+		Source{"work", NewKeePassXC("/home/me/work.kdbx", "/home/me/work.keyx", "")},
+		Source{"team", &Pass{Dir: "/srv/team"}},
+	)
+	dirs, err := m.Dirs()
+	want := []watch.Store{{Mount: "personal", Dir: "/home/me/.password-store"}, {Mount: "team", Dir: "/srv/team"}}
+	if err != nil || !slices.Equal(dirs, want) {
+		t.Errorf("Dirs = %+v, %v, want %+v", dirs, err, want)
+	}
+
+	// the sources whose directories can be found are still watched
+	m = NewMulti(Source{"broken", &Gopass{Path: filepath.Join(t.TempDir(), "missing")}}, Source{"team", &Pass{Dir: "/srv/team"}})
+	dirs, err = m.Dirs()
+	if err == nil || !strings.HasPrefix(err.Error(), "source broken: ") || !slices.Equal(dirs, want[1:]) {
+		t.Errorf("Dirs with a failing source = %+v, %v", dirs, err)
+	}
+}
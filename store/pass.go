@@ -0,0 +1,209 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/otp"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/watch"
+)
+
+// Pass is a store of pass, the standard unix password manager: a directory
+// of files encrypted with gpg, named after their entry.
+//
+// Folders with their own .gpg-id are encrypted for other keys, which gpg
+// picks up when decrypting, so they are listed like any other folder. The
+// .extensions folder of pass extensions and the other hidden files, such as
+// .git, are skipped.
+type Pass struct {
+	// Dir is the directory of the store.
+	Dir string
+	// GPG is the gpg binary decrypting the secrets.
+	GPG string
+}
+
+// DefaultPassDir returns the directory of the store of pass:
+// $PASSWORD_STORE_DIR, or ~/.password-store by default.
+func DefaultPassDir() string {
+	if dir := os.Getenv("PASSWORD_STORE_DIR"); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".password-store")
+}
+
+// NewPass returns the store of pass in dir, or in DefaultPassDir if empty.
+// It decrypts secrets with gpg2 when installed like pass does, or gpg.
+func NewPass(dir string) *Pass {
+	if dir == "" {
+		dir = DefaultPassDir()
+	}
+	gpg := "gpg"
+	if _, err := exec.LookPath("gpg2"); err == nil {
+		gpg = "gpg2"
+	}
+	return &Pass{Dir: dir, GPG: gpg}
+}
+
+// List lists the .gpg files of the store, following the symlinked folders
+// like "pass ls" does.
+func (p *Pass) List() ([]string, error) {
+	root, err := filepath.EvalSymlinks(p.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing the password store: %w", err)
+	}
+	var entries []string
+	if err := list(root, "", map[string]bool{root: true}, &entries); err != nil {
+		return nil, fmt.Errorf("listing the password store: %w", err)
+	}
+	return entries, nil
+}
+
+// list appends to entries the entries of the folder named folder in the
+// store, whose directory is dir. parents are the real paths of the
+// directories being listed, so that symlinks looping back to them are skipped.
+func list(dir, folder string, parents map[string]bool, entries *[]string) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		file := filepath.Join(dir, f.Name())
+		isDir := f.IsDir()
+		if f.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(file)
+			if err != nil {
+				// a dangling symlink
+				continue
+			}
+			isDir = info.IsDir()
+		}
+		if !isDir {
+			if name, ok := strings.CutSuffix(f.Name(), ".gpg"); ok && name != "" {
+				*entries = append(*entries, path.Join(folder, name))
+			}
+			continue
+		}
+		real, err := filepath.EvalSymlinks(file)
+		if err != nil {
+			return err
+		}
+		if parents[real] {
+			continue
+		}
+		parents[real] = true
+		err = list(file, path.Join(folder, f.Name()), parents, entries)
+		delete(parents, real)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// file returns the encrypted file of entry.
+func (p *Pass) file(entry string) (string, error) {
+	if !filepath.IsLocal(entry) {
+		return "", fmt.Errorf("invalid entry %q", entry)
+	}
+	return filepath.Join(p.Dir, entry+".gpg"), nil
+}
+
+// Show decrypts the file of entry with gpg.
+func (p *Pass) Show(entry string) ([]byte, error) {
+	file, err := p.file(entry)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("entry %s is %w", entry, ErrNotFound)
+	}
+	out, err := exec.Command(p.GPG, "--quiet", "--yes", "--batch", "--decrypt", file).Output()
+	if err != nil {
+		return nil, &CommandError{Command: "gpg --decrypt", Err: err}
+	}
+	return out, nil
+}
+
+// ShowKey parses the secret of entry like gopass does.
+func (p *Pass) ShowKey(entry, key string) (string, error) {
+	return showKey(p, entry, key)
+}
+
+// OTP parses the OTP parameters of the secret of entry, such as the
+// otpauth:// line used by pass-otp.
+func (p *Pass) OTP(entry string) (*otp.Key, error) {
+	return showOTP(p, entry)
+}
+
+// Generate encrypts a random password in the file of entry, for the keys of
+// the closest .gpg-id like pass does.
+func (p *Pass) Generate(entry string, length int) (string, error) {
+	file, err := p.file(entry)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(file); err == nil {
+		return "", fmt.Errorf("entry %s already exists", entry)
+	}
+	recipients, err := p.recipients(filepath.Dir(file))
+	if err != nil {
+		return "", err
+	}
+	password, err := generatePassword(length)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return "", err
+	}
+	args := []string{"--quiet", "--yes", "--batch", "--encrypt"}
+	for _, r := range recipients {
+		args = append(args, "--recipient", r)
+	}
+	cmd := exec.Command(p.GPG, append(args, "--output", file)...)
+	cmd.Stdin = strings.NewReader(password + "\n")
+	if err := cmd.Run(); err != nil {
+		return "", &CommandError{Command: "gpg --encrypt", Err: err}
+	}
+	return password, nil
+}
+
+// recipients returns the keys listed in the .gpg-id of dir, or of its
+// closest parent in the store.
+func (p *Pass) recipients(dir string) ([]string, error) {
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, ".gpg-id"))
+		if err == nil {
+			var ids []string
+			for _, line := range strings.Split(string(data), "\n") {
+				if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+					ids = append(ids, line)
+				}
+			}
+			return ids, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		// dir is in the store, entries being local
+		if dir == filepath.Clean(p.Dir) {
+			return nil, fmt.Errorf("no .gpg-id in the password store %s", p.Dir)
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// Dirs returns the directory of the store.
+func (p *Pass) Dirs() ([]watch.Store, error) {
+	return []watch.Store{{Dir: p.Dir}}, nil
+}
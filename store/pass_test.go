@@ -0,0 +1,161 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakePass returns a store in a temporary directory holding files, whose
+// gpg prints the files instead of decrypting them, and writes its input to
+// the --output file instead of encrypting it, logging the arguments to
+// gpg.log next to it.
+func fakePass(t *testing.T, files map[string]string) *Pass {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, "store", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gpg := filepath.Join(dir, "gpg")
+	script := `#!/bin/sh
+echo "$@" >> "$0.log"
+for arg; do
+	[ "$prev" = --output ] && output=$arg
+	prev=$arg file=$arg
+done
+if [ -n "$output" ]; then cat > "$output"; else cat "$file"; fi
+`
+	if err := os.WriteFile(gpg, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return &Pass{Dir: filepath.Join(dir, "store"), GPG: gpg}
+}
+
+func TestPass(t *testing.T) {
+	p := fakePass(t, map[string]string{
+		".gpg-id":                 "me@example.com",
+		"email/work.gpg":          "hunter2\nusername: me\n",
+		"websites/github.com.gpg": "p4ss\notpauth://totp/me?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n",
+		"team/.gpg-id":            "team@example.com",
+		"team/db.gpg":             "shared",
+		"notes.txt":               "not a secret",
+		".extensions/otp.bash":    "#!/bin/bash",
+		".git/objects/x.gpg":      "not a secret either",
+	})
+	entries, err := p.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := []string{"email/work", "team/db", "websites/github.com"}; !slices.Equal(entries, want) {
+		t.Errorf("List = %q, want %q", entries, want)
+	}
+
+	if raw, err := p.Show("team/db"); err != nil || string(raw) != "shared" {
+		t.Errorf("Show = %q, %v", raw, err)
+	}
+	if value, err := p.ShowKey("email/work", "username"); err != nil || value != "me" {
+		t.Errorf("ShowKey = %q, %v", value, err)
+	}
+	if key, err := p.OTP("websites/github.com"); err != nil || key.Digits != 6 {
+		t.Errorf("OTP = %+v, %v", key, err)
+	}
+	if _, err := p.Show("missing"); err == nil || !strings.Contains(err.Error(), "not in the password store") {
+		t.Errorf("Show of a missing entry = %v", err)
+	}
+	if _, err := p.Show("../store/team/db"); err == nil {
+		t.Error("Show should reject entries out of the store")
+	}
+	p.GPG = filepath.Join(t.TempDir(), "missing")
+	if _, err := p.Show("team/db"); err == nil || !strings.Contains(err.Error(), "gpg --decrypt failed") {
+		t.Errorf("Show with a missing gpg = %v", err)
+	}
+}
+
+func TestPassSymlinks(t *testing.T) {
+	p := fakePass(t, map[string]string{"email/work.gpg": "hunter2"})
+	tmp := filepath.Dir(p.Dir)
+	shared := filepath.Join(tmp, "shared")
+	if err := os.MkdirAll(shared, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(shared, "db.gpg"), []byte("p4ss"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		filepath.Join(p.Dir, "team"):            shared,
+		filepath.Join(p.Dir, "email", "loop"):   p.Dir,
+		filepath.Join(p.Dir, "dangling"):        filepath.Join(tmp, "missing"),
+		filepath.Join(tmp, "password-store"):    p.Dir,
+		filepath.Join(shared, "back-to-team"):   shared,
+		filepath.Join(p.Dir, "email", "me.gpg"): filepath.Join(p.Dir, "email", "work.gpg"),
+	} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the root of the store is a symlink too
+	p.Dir = filepath.Join(tmp, "password-store")
+	entries, err := p.List()
+	if want := []string{"email/me", "email/work", "team/db"}; err != nil || !slices.Equal(entries, want) {
+		t.Errorf("List = %q, %v, want %q", entries, err, want)
+	}
+	if raw, err := p.Show("team/db"); err != nil || string(raw) != "p4ss" {
+		t.Errorf("Show of a symlinked folder entry = %q, %v", raw, err)
+	}
+}
+
+func TestPassGenerate(t *testing.T) {
+	p := fakePass(t, map[string]string{
+		".gpg-id":      "me@example.com\n",
+		"team/.gpg-id": "# the team\nalice@example.com\nbob@example.com\n",
+		"team/db.gpg":  "shared",
+	})
+	for entry, recipients := range map[string]string{
+		"email/new":   "--recipient me@example.com",
+		"team/ci/new": "--recipient alice@example.com --recipient bob@example.com",
+	} {
+		password, err := p.Generate(entry, 25)
+		if err != nil || len(password) != 25 {
+			t.Fatalf("Generate(%q) = %q, %v", entry, password, err)
+		}
+		if got, err := p.ShowKey(entry, ""); err != nil || got != password {
+			t.Errorf("generated password of %s = %q, %v, want %q", entry, got, err, password)
+		}
+		args, _ := os.ReadFile(p.GPG + ".log")
+		if !strings.Contains(string(args), recipients+" --output "+filepath.Join(p.Dir, entry+".gpg")) {
+			t.Errorf("gpg was run with %q, want %s", args, recipients)
+		}
+	}
+	if _, err := p.Generate("team/db", 25); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Generate of an existing entry = %v", err)
+	}
+	if err := os.Remove(filepath.Join(p.Dir, ".gpg-id")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Generate("other", 25); err == nil || !strings.Contains(err.Error(), "no .gpg-id") {
+		t.Errorf("Generate without .gpg-id = %v", err)
+	}
+}
+
+func TestDefaultPassDir(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	t.Setenv("PASSWORD_STORE_DIR", "")
+	if got := NewPass("").Dir; got != "/home/me/.password-store" {
+		t.Errorf("default store = %q", got)
+	}
+	t.Setenv("PASSWORD_STORE_DIR", "/srv/pass")
+	if got := NewPass("").Dir; got != "/srv/pass" {
+		t.Errorf("store with PASSWORD_STORE_DIR = %q", got)
+	}
+	if got := NewPass("/tmp/store").Dir; got != "/tmp/store" {
+		t.Errorf("configured store = %q", got)
+	}
+}
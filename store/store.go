@@ -0,0 +1,114 @@
+// Package store abstracts the password managers the plugin reads secrets from.
+package store
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/AnomalRoil/cosmic-gopass-plugin/otp"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/secret"
+	"github.com/AnomalRoil/cosmic-gopass-plugin/watch"
+)
+
+// ErrNotFound is returned for entries missing from a store.
+var ErrNotFound = errors.New("not in the password store")
+
+// CommandError is returned when a command run by a store fails.
+type CommandError struct {
+	// Command is the command and the arguments telling what it did, such
+	// as "gopass ls".
+	Command string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	return e.Command + " failed: " + e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// ErrHOTP is returned for the OTP codes of HOTP keys, whose counter would
+// have to be incremented in the secret every time a code is used.
+var ErrHOTP = errors.New("HOTP keys are not supported, only TOTP ones")
+
+// Store is a password store.
+type Store interface {
+	// List returns the names of the entries of the store.
+	List() ([]string, error)
+	// Show returns the whole decrypted content of entry, its password on
+	// the first line followed by "key: value" lines as parsed by the secret
+	// package.
+	Show(entry string) ([]byte, error)
+	// ShowKey returns the value of key in the secret of entry, or its
+	// password when key is empty.
+	ShowKey(entry, key string) (string, error)
+	// OTP returns the OTP parameters stored in the secret of entry.
+	OTP(entry string) (*otp.Key, error)
+	// Generate creates entry with a new random password of length
+	// characters, and returns the password.
+	Generate(entry string, length int) (string, error)
+}
+
+// Watchable is implemented by the stores made of directories of encrypted
+// files, which can be watched for changes.
+type Watchable interface {
+	// Dirs returns the directories of the store.
+	Dirs() ([]watch.Store, error)
+}
+
+// showKey implements Store.ShowKey with Show.
+func showKey(s Store, entry, key string) (string, error) {
+	raw, err := s.Show(entry)
+	if err != nil {
+		return "", err
+	}
+	sec := secret.Parse(raw)
+	if key == "" {
+		return sec.Password, nil
+	}
+	value, ok := sec.Get(key)
+	if !ok {
+		return "", fmt.Errorf("entry %s has no key %q", entry, key)
+	}
+	return value, nil
+}
+
+// showOTP implements Store.OTP with Show.
+func showOTP(s Store, entry string) (*otp.Key, error) {
+	raw, err := s.Show(entry)
+	if err != nil {
+		return nil, err
+	}
+	key, err := otp.FromSecret(secret.Parse(raw))
+	if err != nil {
+		return nil, fmt.Errorf("entry %s: %w", entry, err)
+	}
+	if key.Type == "hotp" {
+		return nil, fmt.Errorf("entry %s: %w", entry, ErrHOTP)
+	}
+	return key, nil
+}
+
+// passwordChars are the characters of the generated passwords, the
+// alphanumeric and punctuation characters like pass generates.
+const passwordChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// generatePassword returns a random password of length characters.
+func generatePassword(length int) (string, error) {
+	if length < 1 {
+		return "", fmt.Errorf("invalid password length %d", length)
+	}
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(passwordChars))))
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordChars[n.Int64()]
+	}
+	return string(password), nil
+}
@@ -0,0 +1,397 @@
+// Package watch watches password stores with inotify, to report the entries
+// added and removed without listing the whole store again.
+package watch
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Extensions are the extensions of the encrypted secret files, of the gpg
+// and age backends.
+var Extensions = []string{".gpg", ".age"}
+
+// Store is a password store.
+type Store struct {
+	// Mount is the folder the store is mounted on in the entry names, empty
+	// for the root store.
+	Mount string
+	// Dir is the directory of the store.
+	Dir string
+}
+
+// Change is a change of the entries of the watched stores.
+type Change struct {
+	// Added and Removed are the entries added and removed, with Removed
+	// applying first. Removed entries ending with "/" are whole folders.
+	Added, Removed []string
+	// Reload is set when changes were lost: the stores were watched again
+	// from scratch, and must be listed again.
+	Reload bool
+}
+
+// mask is the inotify events watched.
+const mask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// dir is a watched directory.
+type dir struct {
+	store *Store
+	// rel is the path of the directory in the store, "" for its root.
+	rel string
+}
+
+// path returns the path of the file name in d.
+func (d dir) path(name string) string {
+	return filepath.Join(d.store.Dir, d.rel, name)
+}
+
+// entry returns the entry of the file or folder name in d.
+func (d dir) entry(name string) string {
+	return strings.TrimPrefix(d.store.Mount+"/"+filepath.Join(d.rel, name), "/")
+}
+
+// parents returns the real paths of d and of the directories above it in
+// its store.
+func (d dir) parents() map[string]bool {
+	parents := make(map[string]bool)
+	for rel := d.rel; ; rel = filepath.Dir(rel) {
+		if real, err := filepath.EvalSymlinks(filepath.Join(d.store.Dir, rel)); err == nil {
+			parents[real] = true
+		}
+		if rel == "" || rel == "." {
+			return parents
+		}
+	}
+}
+
+// Watcher watches the directories of password stores.
+type Watcher struct {
+	stores []Store
+	// fd is the inotify file descriptor, read through file. file.Fd() would
+	// make reads blocking.
+	fd   int
+	file *os.File
+	// dirs are the watched directories by watch descriptor, several when
+	// symlinks lead to the same directory, and wds the watch descriptors by
+	// path. They are only accessed by Run once created.
+	dirs map[int32][]dir
+	wds  map[string]int32
+}
+
+// New watches the directories of stores.
+func New(stores []Store) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+	// a non blocking file is read with the runtime poller, and closing it
+	// interrupts reads
+	w := &Watcher{
+		stores: stores,
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+	}
+	if err := w.watchStores(); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// watchStores watches all the directories of the stores from scratch,
+// skipping the stores whose directory doesn't exist.
+func (w *Watcher) watchStores() error {
+	for wd := range w.dirs {
+		syscall.InotifyRmWatch(w.fd, uint32(wd))
+	}
+	w.dirs, w.wds = make(map[int32][]dir), make(map[string]int32)
+	for i := range w.stores {
+		err := w.add(dir{store: &w.stores[i]}, make(map[string]bool), nil)
+		if _, watched := w.wds[w.stores[i].Dir]; !watched && errors.Is(err, fs.ErrNotExist) {
+			log.Printf("WARNING: not watching the store in %s, which doesn't exist", w.stores[i].Dir)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	return w.file.Close()
+}
+
+// hidden reports whether name is hidden, such as .git or .gpg-id.
+func hidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// secretName returns the name of the secret stored in the file name.
+func secretName(name string) (string, bool) {
+	for _, ext := range Extensions {
+		if s, ok := strings.CutSuffix(name, ext); ok && s != "" {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// add watches d and its subdirectories, and calls found with the entries in
+// them. Symlinked subdirectories are followed like pass does, except those
+// pointing to d or to one of parents, the real paths of the directories
+// above d.
+func (w *Watcher) add(d dir, parents map[string]bool, found func(entry string)) error {
+	path := d.path("")
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("watching %s: %w", path, err)
+	}
+	if parents[real] {
+		return nil
+	}
+	parents[real] = true
+	defer delete(parents, real)
+	wd, err := syscall.InotifyAddWatch(w.fd, path, mask)
+	if err != nil {
+		return fmt.Errorf("watching %s: %w", path, err)
+	}
+	// a directory moved within the store keeps its watch descriptor, and a
+	// directory also reached through a symlink shares it
+	w.dirs[int32(wd)] = append(slices.DeleteFunc(w.dirs[int32(wd)], func(o dir) bool { return o.path("") == path }), d)
+	w.wds[path] = int32(wd)
+
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return fmt.Errorf("watching %s: %w", path, err)
+	}
+	for _, f := range files {
+		if hidden(f.Name()) {
+			continue
+		}
+		isDir := f.IsDir()
+		if f.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(filepath.Join(path, f.Name()))
+			if err != nil {
+				// a dangling symlink
+				continue
+			}
+			isDir = info.IsDir()
+		}
+		if isDir {
+			if err := w.add(dir{d.store, filepath.Join(d.rel, f.Name())}, parents, found); err != nil {
+				return err
+			}
+			continue
+		}
+		if name, ok := secretName(f.Name()); ok && found != nil {
+			found(d.entry(name))
+		}
+	}
+	return nil
+}
+
+// remove stops watching the directory at path and its subdirectories.
+func (w *Watcher) remove(path string) {
+	for p, wd := range w.wds {
+		if p != path && !strings.HasPrefix(p, path+"/") {
+			continue
+		}
+		delete(w.wds, p)
+		w.dirs[wd] = slices.DeleteFunc(w.dirs[wd], func(d dir) bool { return d.path("") == p })
+		if len(w.dirs[wd]) == 0 {
+			// the kernel already removed the watch if the directory was deleted
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+}
+
+// event is an inotify event.
+type event struct {
+	wd   int32
+	mask uint32
+	name string
+}
+
+// readEvents reads the next events.
+func (w *Watcher) readEvents(buf []byte) ([]event, error) {
+	n, err := w.file.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	var events []event
+	for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+		ev := event{
+			wd:   int32(binary.NativeEndian.Uint32(buf[off:])),
+			mask: binary.NativeEndian.Uint32(buf[off+4:]),
+		}
+		nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+		off += syscall.SizeofInotifyEvent
+		if off+nameLen > n {
+			return nil, fmt.Errorf("inotify: truncated event")
+		}
+		ev.name = strings.TrimRight(string(buf[off:off+nameLen]), "\x00")
+		off += nameLen
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+// changes accumulates the changes of several events.
+type changes struct {
+	// entries are the changed entries, and whether they were added.
+	entries map[string]bool
+	folders []string
+	reload  bool
+}
+
+func (c *changes) empty() bool {
+	return len(c.entries) == 0 && len(c.folders) == 0 && !c.reload
+}
+
+func (c *changes) removeFolder(folder string) {
+	for e := range c.entries {
+		if strings.HasPrefix(e, folder) {
+			delete(c.entries, e)
+		}
+	}
+	c.folders = append(c.folders, folder)
+}
+
+func (c *changes) change() Change {
+	ch := Change{Removed: c.folders, Reload: c.reload}
+	for _, e := range slices.Sorted(maps.Keys(c.entries)) {
+		if c.entries[e] {
+			ch.Added = append(ch.Added, e)
+		} else {
+			ch.Removed = append(ch.Removed, e)
+		}
+	}
+	return ch
+}
+
+// handle records the changes of ev.
+func (w *Watcher) handle(ev event, c *changes) {
+	if ev.mask&syscall.IN_Q_OVERFLOW != 0 {
+		c.reload = true
+		return
+	}
+	dirs := w.dirs[ev.wd]
+	if ev.mask&syscall.IN_IGNORED != 0 {
+		// the directory was deleted
+		delete(w.dirs, ev.wd)
+		for _, d := range dirs {
+			if w.wds[d.path("")] == ev.wd {
+				delete(w.wds, d.path(""))
+			}
+		}
+		return
+	}
+	if ev.name == "" || hidden(ev.name) {
+		return
+	}
+	// adding a folder may watch other paths of the same directory
+	for _, d := range slices.Clone(dirs) {
+		w.handleIn(d, ev, c)
+	}
+}
+
+// handleIn records the changes of ev in the directory d.
+func (w *Watcher) handleIn(d dir, ev event, c *changes) {
+	added := ev.mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0
+	path := d.path(ev.name)
+	// the events of symlinks to directories don't have IN_ISDIR
+	_, watched := w.wds[path]
+	isDir := ev.mask&syscall.IN_ISDIR != 0 || !added && watched
+	if !isDir && added {
+		if info, err := os.Stat(path); err == nil {
+			isDir = info.IsDir()
+		}
+	}
+	if isDir {
+		if !added {
+			w.remove(path)
+			c.removeFolder(d.entry(ev.name) + "/")
+			return
+		}
+		err := w.add(dir{d.store, filepath.Join(d.rel, ev.name)}, d.parents(), func(entry string) { c.entries[entry] = true })
+		if err != nil && !errors.Is(err, syscall.ENOENT) {
+			// the changes in the directory may be missed
+			c.reload = true
+		}
+		return
+	}
+	if name, ok := secretName(ev.name); ok {
+		c.entries[d.entry(name)] = added
+	}
+}
+
+// Run reports the changes of the watched stores to apply, once no more
+// changes happened for delay, until ctx is done or the watcher is closed.
+func (w *Watcher) Run(ctx context.Context, delay time.Duration, apply func(Change)) error {
+	type result struct {
+		events []event
+		err    error
+	}
+	results := make(chan result)
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			events, err := w.readEvents(buf)
+			select {
+			case results <- result{events, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	c := &changes{entries: make(map[string]bool)}
+	timer := time.NewTimer(delay)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.Close()
+			return ctx.Err()
+		case r := <-results:
+			if errors.Is(r.err, os.ErrClosed) {
+				return nil
+			}
+			if r.err != nil {
+				return fmt.Errorf("inotify: %w", r.err)
+			}
+			for _, ev := range r.events {
+				w.handle(ev, c)
+			}
+			if !c.empty() {
+				timer.Reset(delay)
+			}
+		case <-timer.C:
+			if c.reload {
+				// the directories created meanwhile may not be watched
+				if err := w.watchStores(); err != nil {
+					log.Printf("ERROR: watching the stores again: %v", err)
+				}
+			}
+			apply(c.change())
+			c = &changes{entries: make(map[string]bool)}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package watch
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// entries applies the changes reported by a watcher to a set of entries.
+type entries struct {
+	t       *testing.T
+	changes chan Change
+	current map[string]bool
+}
+
+func watch(t *testing.T, stores []Store) *entries {
+	t.Helper()
+	w, err := New(stores)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &entries{t: t, changes: make(chan Change, 16), current: make(map[string]bool)}
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx, 10*time.Millisecond, func(c Change) { e.changes <- c })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != context.Canceled {
+			t.Errorf("Run = %v, want %v", err, context.Canceled)
+		}
+	})
+	return e
+}
+
+// wait applies the reported changes until the entries are want.
+func (e *entries) wait(want ...string) {
+	e.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		got := slices.Sorted(maps.Keys(e.current))
+		if slices.Equal(got, want) {
+			return
+		}
+		select {
+		case c := <-e.changes:
+			if c.Reload {
+				e.t.Fatal("unexpected reload")
+			}
+			for _, r := range c.Removed {
+				for entry := range e.current {
+					if entry == r || strings.HasSuffix(r, "/") && strings.HasPrefix(entry, r) {
+						delete(e.current, entry)
+					}
+				}
+			}
+			for _, a := range c.Added {
+				e.current[a] = true
+			}
+		case <-timeout:
+			e.t.Fatalf("entries = %q, want %q", got, want)
+		}
+	}
+}
+
+func write(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "root")
+	work := filepath.Join(tmp, "work")
+	write(t, filepath.Join(root, "existing.gpg"))
+	write(t, filepath.Join(work, ".gpg-id"))
+	e := watch(t, []Store{{Dir: root}, {Mount: "work", Dir: work}})
+
+	write(t, filepath.Join(root, "a.gpg"))
+	write(t, filepath.Join(root, "notes.txt"))
+	write(t, filepath.Join(root, ".git", "objects", "x.gpg"))
+	e.wait("a")
+
+	// a new folder is watched, including what was written before it was
+	write(t, filepath.Join(root, "sub", "deep", "b.age"))
+	write(t, filepath.Join(work, "c.gpg"))
+	e.wait("a", "sub/deep/b", "work/c")
+	write(t, filepath.Join(root, "sub", "deep", "b2.gpg"))
+	e.wait("a", "sub/deep/b", "sub/deep/b2", "work/c")
+
+	// folders moved within, into and out of the stores
+	if err := os.Rename(filepath.Join(root, "sub"), filepath.Join(root, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	e.wait("a", "moved/deep/b", "moved/deep/b2", "work/c")
+	write(t, filepath.Join(root, "moved", "deep", "b3.gpg"))
+	e.wait("a", "moved/deep/b", "moved/deep/b2", "moved/deep/b3", "work/c")
+	write(t, filepath.Join(tmp, "outside", "d.gpg"))
+	if err := os.Rename(filepath.Join(tmp, "outside"), filepath.Join(work, "in")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(root, "moved"), filepath.Join(tmp, "gone")); err != nil {
+		t.Fatal(err)
+	}
+	e.wait("a", "work/c", "work/in/d")
+	// the folder moved out is not watched anymore
+	write(t, filepath.Join(tmp, "gone", "e.gpg"))
+
+	if err := os.Remove(filepath.Join(root, "a.gpg")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(work, "in")); err != nil {
+		t.Fatal(err)
+	}
+	e.wait("work/c")
+}
+
+func TestWatchSymlinks(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "root")
+	shared := filepath.Join(tmp, "shared")
+	write(t, filepath.Join(root, "a.gpg"))
+	write(t, filepath.Join(shared, "s.gpg"))
+	if err := os.Symlink(shared, filepath.Join(root, "linked")); err != nil {
+		t.Fatal(err)
+	}
+	// a loop, which is not followed
+	if err := os.Symlink(root, filepath.Join(shared, "up")); err != nil {
+		t.Fatal(err)
+	}
+	e := watch(t, []Store{{Dir: root}})
+
+	// the folders of symlinks are watched, and so are the ones created later
+	write(t, filepath.Join(shared, "t.gpg"))
+	e.wait("linked/t")
+	if err := os.Symlink(shared, filepath.Join(root, "again")); err != nil {
+		t.Fatal(err)
+	}
+	e.wait("again/s", "again/t", "linked/t")
+	write(t, filepath.Join(shared, "u.gpg"))
+	e.wait("again/s", "again/t", "again/u", "linked/t", "linked/u")
+
+	// removing a symlink removes its folder, and the other paths of its
+	// target are still watched
+	e.current["a"], e.current["linked/s"] = true, true
+	if err := os.Remove(filepath.Join(root, "linked")); err != nil {
+		t.Fatal(err)
+	}
+	e.wait("a", "again/s", "again/t", "again/u")
+	write(t, filepath.Join(shared, "v.gpg"))
+	e.wait("a", "again/s", "again/t", "again/u", "again/v")
+}
+
+func TestWatchMissingStore(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "root")
+	write(t, filepath.Join(root, ".gpg-id"))
+	e := watch(t, []Store{{Mount: "gone", Dir: filepath.Join(tmp, "missing")}, {Dir: root}})
+	write(t, filepath.Join(root, "a.gpg"))
+	e.wait("a")
+}
+
+func TestWatchStoresAgain(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "root")
+	write(t, filepath.Join(root, "a", "1.gpg"))
+	w, err := New([]Store{{Dir: root}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer w.Close()
+	// a folder whose creation was lost, like after an overflow
+	write(t, filepath.Join(root, "b", "2.gpg"))
+	if err := os.RemoveAll(filepath.Join(root, "a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.watchStores(); err != nil {
+		t.Fatalf("watchStores: %v", err)
+	}
+	want := []string{root, filepath.Join(root, "b")}
+	if got := slices.Sorted(maps.Keys(w.wds)); !slices.Equal(got, want) || len(w.dirs) != len(want) {
+		t.Errorf("watched %q and %d dirs, want %q", got, len(w.dirs), want)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	c := &changes{entries: make(map[string]bool)}
+	c.entries["a/1"] = true
+	c.entries["b/1"] = false
+	c.entries["c"] = true
+	c.removeFolder("a/")
+	c.entries["a/2"] = true
+	c.entries["c"] = false
+	want := Change{Added: []string{"a/2"}, Removed: []string{"a/", "b/1", "c"}}
+	if got := c.change(); !reflect.DeepEqual(got, want) {
+		t.Errorf("change = %+v, want %+v", got, want)
+	}
+}